@@ -0,0 +1,240 @@
+package align
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+
+	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/padding"
+)
+
+// Alignment describes where lines are placed within the width.
+type Alignment int
+
+// Available alignments.
+const (
+	Left Alignment = iota
+	Center
+	Right
+)
+
+// Bias decides which side gets the odd leftover cell when centering.
+type Bias int
+
+// Available biases.
+const (
+	// BiasLeft moves centered lines a cell to the left.
+	BiasLeft Bias = iota
+	// BiasRight moves centered lines a cell to the right.
+	BiasRight
+)
+
+// Writer aligns every line of its content within a given width. Lines wider
+// than the width are left alone.
+type Writer struct {
+	// Width is the printable cell width lines get filled up to.
+	Width uint
+	// Align decides where lines are placed within the width.
+	Align Alignment
+	// Bias decides which side gets the odd cell of fill when centering.
+	Bias Bias
+
+	// Fill is the rune lines are filled up with. It defaults to a space and
+	// should be a single cell wide.
+	Fill rune
+	// PadFunc writes a single cell of fill, e.g. a styled space. It takes
+	// precedence over Fill.
+	PadFunc padding.PaddingFunc
+
+	ansiWriter *ansi.Writer
+	buf        bytes.Buffer
+	cache      bytes.Buffer
+	line       bytes.Buffer
+	lineLen    int
+	ansi       bool
+}
+
+// NewWriter returns a new instance of an align-writer, aligning lines within
+// width.
+func NewWriter(width uint, align Alignment) *Writer {
+	w := &Writer{
+		Width: width,
+		Align: align,
+	}
+	w.ansiWriter = &ansi.Writer{
+		Forward: &w.buf,
+	}
+	return w
+}
+
+// NewWriterPipe returns a new instance of an align-writer, writing every
+// aligned line to forward as soon as it's complete, rather than accumulating
+// the entire result.
+func NewWriterPipe(forward io.Writer, width uint, align Alignment) *Writer {
+	return &Writer{
+		Width: width,
+		Align: align,
+		ansiWriter: &ansi.Writer{
+			Forward: forward,
+		},
+	}
+}
+
+// Bytes is shorthand for declaring a new default align-writer instance,
+// used to immediately align a byte slice.
+func Bytes(b []byte, width uint, align Alignment) []byte {
+	f := NewWriter(width, align)
+	_, _ = f.Write(b)
+	_ = f.Flush()
+
+	return f.Bytes()
+}
+
+// String is shorthand for declaring a new default align-writer instance,
+// used to immediately align a string.
+func String(s string, width uint, align Alignment) string {
+	return string(Bytes([]byte(s), width, align))
+}
+
+// Write is used to write content to the align buffer. Lines are held back
+// until they are complete, as their width decides the fill.
+func (w *Writer) Write(b []byte) (int, error) {
+	for _, c := range string(b) {
+		if c == ansi.Marker {
+			// ANSI escape sequence
+			w.ansi = true
+		} else if w.ansi {
+			if ansi.IsTerminator(c) {
+				// ANSI sequence terminated
+				w.ansi = false
+			}
+		} else if c == '\n' {
+			// end of current line
+			if err := w.writeLine(true); err != nil {
+				return 0, err
+			}
+			continue
+		} else {
+			w.lineLen += runewidth.RuneWidth(c)
+		}
+
+		_, _ = w.line.WriteRune(c)
+	}
+
+	return len(b), nil
+}
+
+// writeLine writes the current line with its fill. Styles are reset before
+// the fill on the right and restored after the fill on the left, so the fill
+// stays unstyled.
+func (w *Writer) writeLine(newline bool) error {
+	line := w.line.Bytes()
+	var cr bool
+	if bytes.HasSuffix(line, []byte{'\r'}) {
+		line, cr = line[:len(line)-1], true
+	}
+
+	var left, right int
+	if extra := int(w.Width) - w.lineLen; extra > 0 {
+		switch w.Align {
+		case Left:
+			right = extra
+		case Right:
+			left = extra
+		case Center:
+			left = extra / 2
+			if w.Bias == BiasRight {
+				left = extra - left
+			}
+			right = extra - left
+		}
+	}
+
+	if err := w.pad(left); err != nil {
+		return err
+	}
+	if len(line) > 0 {
+		if w.ansiWriter.LastSequence() != "" {
+			w.ansiWriter.RestoreAnsi()
+		}
+		if _, err := w.ansiWriter.Write(line); err != nil {
+			return err
+		}
+		if w.ansiWriter.LastSequence() != "" {
+			w.ansiWriter.ResetAnsi()
+		}
+	}
+	if err := w.pad(right); err != nil {
+		return err
+	}
+
+	if cr {
+		if _, err := w.ansiWriter.Write([]byte{'\r'}); err != nil {
+			return err
+		}
+	}
+	if newline {
+		if _, err := w.ansiWriter.Write([]byte{'\n'}); err != nil {
+			return err
+		}
+	}
+
+	w.line.Reset()
+	w.lineLen = 0
+	return nil
+}
+
+// pad writes n cells of fill.
+func (w *Writer) pad(n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	if w.PadFunc != nil {
+		for i := 0; i < n; i++ {
+			w.PadFunc(w.ansiWriter.Forward)
+		}
+		return nil
+	}
+
+	fill := w.Fill
+	if fill == 0 {
+		fill = ' '
+	}
+	_, err := io.WriteString(w.ansiWriter.Forward, strings.Repeat(string(fill), n))
+	return err
+}
+
+// Close will finish the align operation.
+func (w *Writer) Close() (err error) {
+	return w.Flush()
+}
+
+// Bytes returns the aligned result as a byte slice.
+func (w *Writer) Bytes() []byte {
+	return w.cache.Bytes()
+}
+
+// String returns the aligned result as a string.
+func (w *Writer) String() string {
+	return w.cache.String()
+}
+
+// Flush will finish the align operation. Always call it before trying to
+// retrieve the final result.
+func (w *Writer) Flush() (err error) {
+	if w.line.Len() > 0 {
+		if err = w.writeLine(false); err != nil {
+			return
+		}
+	}
+
+	w.cache.Reset()
+	_, err = w.buf.WriteTo(&w.cache)
+	w.ansi = false
+
+	return
+}
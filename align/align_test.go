@@ -0,0 +1,223 @@
+package align
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/muesli/reflow/ansi"
+)
+
+func TestAlign(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input    string
+		Expected string
+		Width    uint
+		Align    Alignment
+		Bias     Bias
+	}{
+		// No-op, should pass through:
+		{
+			"foobar",
+			"foobar",
+			0,
+			Right,
+			BiasLeft,
+		},
+		// Left alignment fills up the right:
+		{
+			"foo\nfoobar",
+			"foo   \nfoobar",
+			6,
+			Left,
+			BiasLeft,
+		},
+		// Right alignment:
+		{
+			"foo\nfoobar",
+			"   foo\nfoobar",
+			6,
+			Right,
+			BiasLeft,
+		},
+		// Centering:
+		{
+			"foo\nfoobar",
+			"  foo  \nfoobar ",
+			7,
+			Center,
+			BiasLeft,
+		},
+		{
+			"foo\nfoobar",
+			"  foo  \n foobar",
+			7,
+			Center,
+			BiasRight,
+		},
+		{
+			"foo",
+			" foo  ",
+			6,
+			Center,
+			BiasLeft,
+		},
+		{
+			"foo",
+			"  foo ",
+			6,
+			Center,
+			BiasRight,
+		},
+		// Lines wider than the width are left alone:
+		{
+			"foobar",
+			"foobar",
+			4,
+			Center,
+			BiasLeft,
+		},
+		// Empty lines get filled, empty trailing lines don't:
+		{
+			"foo\n\nbar\n",
+			"  foo\n     \n  bar\n",
+			5,
+			Right,
+			BiasLeft,
+		},
+		// Line endings are kept:
+		{
+			"foo\r\nbar",
+			"  foo\r\n  bar",
+			5,
+			Right,
+			BiasLeft,
+		},
+		// ANSI sequence codes and double-width characters:
+		{
+			"\x1B[38;2;249;38;114m你好\x1B[0m",
+			"  \x1B[38;2;249;38;114m你好\x1B[0m  ",
+			8,
+			Center,
+			BiasLeft,
+		},
+		// The fill stays unstyled:
+		{
+			"\x1B[7mfoo\nbar\x1B[0m",
+			"  \x1B[7mfoo\x1B[0m\n  \x1B[7mbar\x1B[0m",
+			5,
+			Right,
+			BiasLeft,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Width, tc.Align)
+		f.Bias = tc.Bias
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if err := f.Close(); err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestAlignFill(t *testing.T) {
+	t.Parallel()
+
+	f := NewWriter(7, Center)
+	f.Fill = '.'
+	_, _ = f.Write([]byte("foo"))
+	_ = f.Close()
+
+	exp := "..foo.."
+	if f.String() != exp {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", exp, f.String())
+	}
+
+	f = NewWriter(7, Center)
+	f.Fill = '.'
+	f.PadFunc = func(w io.Writer) {
+		_, _ = w.Write([]byte("-"))
+	}
+	_, _ = f.Write([]byte("foo"))
+	_ = f.Close()
+
+	exp = "--foo--"
+	if f.String() != exp {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", exp, f.String())
+	}
+}
+
+func TestAlignString(t *testing.T) {
+	t.Parallel()
+
+	actual := String("foo", 7, Center)
+	expected := "  foo  "
+	if actual != expected {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
+	}
+}
+
+func BenchmarkAlignString(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		b.ReportAllocs()
+		b.ResetTimer()
+		for pb.Next() {
+			String("foo", 7, Center)
+		}
+	})
+}
+
+func TestNewWriterPipe(t *testing.T) {
+	t.Parallel()
+
+	b := &bytes.Buffer{}
+	f := NewWriterPipe(b, 6, Right)
+
+	if _, err := f.Write([]byte("foo\nbar")); err != nil {
+		t.Error(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Error(err)
+	}
+
+	actual := b.String()
+	expected := "   foo\n   bar"
+
+	if actual != expected {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
+	}
+}
+
+func TestWriter_Error(t *testing.T) {
+	t.Parallel()
+
+	f := &Writer{
+		Width:      6,
+		ansiWriter: &ansi.Writer{Forward: fakeWriter{}},
+	}
+
+	if _, err := f.Write([]byte("foo\n")); err != fakeErr {
+		t.Error(err)
+	}
+}
+
+var fakeErr = errors.New("fake error")
+
+type fakeWriter struct{}
+
+func (fakeWriter) Write(_ []byte) (int, error) {
+	return 0, fakeErr
+}
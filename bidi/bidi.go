@@ -0,0 +1,446 @@
+// Package bidi reorders lines of bidirectional text, e.g. mixing Hebrew or
+// Arabic with Latin script, from logical to visual order.
+//
+// It implements the implicit rules of the Unicode Bidirectional Algorithm
+// (UAX #9) for each line on its own, using either the line's own direction or
+// that of the paragraph it was wrapped from. Explicit embeddings, overrides
+// and isolates are ignored, as is the pairing of brackets.
+package bidi
+
+import (
+	"strings"
+
+	"github.com/muesli/reflow/ansi"
+)
+
+// item is a printable rune, along with the escape sequences styling it.
+type item struct {
+	r     rune
+	class class
+	level int
+
+	// style holds the SGR sequences active for this rune, while pre holds
+	// any other sequences directly preceding it.
+	style string
+	pre   string
+}
+
+// Direction is the base direction of a paragraph.
+type Direction int
+
+// Available directions.
+const (
+	// Auto determines the direction of every line from its first strong
+	// rune, treating it as a paragraph of its own.
+	Auto Direction = iota
+	LeftToRight
+	RightToLeft
+)
+
+// RuneDirection returns the direction of a strong rune, or Auto for any other
+// rune. The first strong rune of a paragraph determines its direction.
+func RuneDirection(c rune) Direction {
+	switch lookup(c) {
+	case classL:
+		return LeftToRight
+	case classR, classAL:
+		return RightToLeft
+	}
+	return Auto
+}
+
+// String reorders every line of s from logical to visual order. Lines may be
+// terminated by LF, CRLF or CR. Escape sequences stay attached to the runes
+// they style, so styles are re-emitted wherever reordering moved runs around.
+func String(s string) string {
+	if !hasRTL(s) {
+		return s
+	}
+
+	s, _ = Reorder(s, "")
+	return s
+}
+
+// Reorder is like String, but starts out with the given SGR state, as left
+// behind by any preceding text. It returns the reordered text along with the
+// SGR state active at its end, so text can be reordered in chunks of complete
+// lines.
+func Reorder(s, style string) (string, string) {
+	return ReorderDirection(s, style, Auto)
+}
+
+// ReorderDirection is like Reorder, but uses the given paragraph direction for
+// every line. This keeps the lines of a wrapped paragraph in a common
+// direction, rather than each line determining its own.
+func ReorderDirection(s, style string, dir Direction) (string, string) {
+	if dir != RightToLeft && !hasRTL(s) {
+		_, _, style = parse(s, style)
+		return s, style
+	}
+
+	var b strings.Builder
+	for len(s) > 0 {
+		i := strings.IndexAny(s, "\r\n")
+		if i < 0 {
+			i = len(s)
+		}
+		j := i
+		if strings.HasPrefix(s[i:], "\r\n") {
+			j += 2
+		} else if i < len(s) {
+			j++
+		}
+
+		var line string
+		line, style = reorderLine(s[:i], style, dir)
+		_, _ = b.WriteString(line)
+		_, _ = b.WriteString(s[i:j])
+		s = s[j:]
+	}
+	return b.String(), style
+}
+
+// Bytes reorders every line of b from logical to visual order.
+func Bytes(b []byte) []byte {
+	return []byte(String(string(b)))
+}
+
+// hasRTL reports whether s contains any right-to-left runes or Arabic
+// numbers, without which visual and logical order are identical.
+func hasRTL(s string) bool {
+	for _, c := range s {
+		if c < 0x0590 {
+			continue
+		}
+		switch lookup(c) {
+		case classR, classAL, classAN:
+			return true
+		}
+	}
+	return false
+}
+
+// reorderLine reorders a single line, given the SGR state active at its start
+// and the paragraph direction. It returns the reordered line and the SGR state
+// active at its end.
+func reorderLine(line, style string, dir Direction) (string, string) {
+	items, trailer, final := parse(line, style)
+	if dir != RightToLeft && !hasRTL(line) {
+		return line, final
+	}
+
+	resolve(items, dir)
+
+	// L2: reverse any sequence at or above each odd level, from the highest
+	// level down to the lowest odd one
+	var highest int
+	lowest := 1 << 30
+	for _, it := range items {
+		if it.level > highest {
+			highest = it.level
+		}
+		if it.level%2 == 1 && it.level < lowest {
+			lowest = it.level
+		}
+	}
+	for lvl := highest; lvl >= lowest; lvl-- {
+		for i := 0; i < len(items); {
+			if items[i].level < lvl {
+				i++
+				continue
+			}
+			j := i
+			for j < len(items) && items[j].level >= lvl {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				items[a], items[b] = items[b], items[a]
+			}
+			i = j
+		}
+	}
+
+	var b strings.Builder
+	cur := style
+	for _, it := range items {
+		if it.style != cur {
+			if cur != "" {
+				_, _ = b.WriteString("\x1b[0m")
+			}
+			_, _ = b.WriteString(it.style)
+			cur = it.style
+		}
+		_, _ = b.WriteString(it.pre)
+
+		r := it.r
+		if it.level%2 == 1 {
+			// L4: mirror brackets within right-to-left runs
+			r = mirror(r)
+		}
+		_, _ = b.WriteRune(r)
+	}
+
+	// leave the style state as the logical line did, so any following text
+	// is unaffected by reordering
+	if cur != final {
+		if cur != "" {
+			_, _ = b.WriteString("\x1b[0m")
+		}
+		_, _ = b.WriteString(final)
+	}
+	_, _ = b.WriteString(trailer)
+
+	return b.String(), final
+}
+
+// parse splits a line into its printable runes, starting out with the given
+// SGR state. Sequences following the last rune other than SGR sequences are
+// returned as trailer, along with the final SGR state of the line.
+func parse(line, style string) ([]item, string, string) {
+	var (
+		items []item
+		seq   strings.Builder
+		pre   string
+		esc   bool
+	)
+
+	for _, c := range line {
+		if c == ansi.Marker {
+			esc = true
+			_, _ = seq.WriteRune(c)
+		} else if esc {
+			_, _ = seq.WriteRune(c)
+			if ansi.IsTerminator(c) {
+				esc = false
+
+				s := seq.String()
+				seq.Reset()
+				switch {
+				case s == "\x1b[0m" || s == "\x1b[m":
+					style = ""
+				case c == 'm':
+					style += s
+				default:
+					pre += s
+				}
+			}
+		} else {
+			items = append(items, item{
+				r:     c,
+				class: lookup(c),
+				style: style,
+				pre:   pre,
+			})
+			pre = ""
+		}
+	}
+
+	return items, pre + seq.String(), style
+}
+
+// resolve determines the embedding level of every item, given the paragraph
+// direction.
+func resolve(items []item, dir Direction) {
+	// P2, P3: unless given, the first strong type determines the paragraph
+	// level
+	para := 0
+	for _, it := range items {
+		if dir != Auto {
+			break
+		}
+		if d := RuneDirection(it.r); d != Auto {
+			dir = d
+		}
+	}
+	if dir == RightToLeft {
+		para = 1
+	}
+	sos := classL
+	if para == 1 {
+		sos = classR
+	}
+
+	// X9: boundary neutrals are removed from the resolution
+	var idx []int
+	for i, it := range items {
+		if it.class != classBN {
+			idx = append(idx, i)
+		}
+	}
+	t := make([]class, len(idx))
+	for i, j := range idx {
+		t[i] = items[j].class
+	}
+
+	resolveWeak(t, sos)
+	resolveNeutral(t, sos)
+
+	// I1, I2: implicit levels
+	for i, j := range idx {
+		lvl := para
+		switch {
+		case para == 0 && t[i] == classR:
+			lvl++
+		case para == 0 && (t[i] == classAN || t[i] == classEN):
+			lvl += 2
+		case para == 1 && (t[i] == classL || t[i] == classAN || t[i] == classEN):
+			lvl++
+		}
+		items[j].level = lvl
+	}
+
+	// boundary neutrals take the level of their preceding rune
+	for i := range items {
+		if items[i].class == classBN {
+			items[i].level = para
+			if i > 0 {
+				items[i].level = items[i-1].level
+			}
+		}
+	}
+
+	// L1: separators and trailing whitespace are reset to the paragraph level
+	trailing := true
+	for i := len(items) - 1; i >= 0; i-- {
+		switch items[i].class {
+		case classS, classB:
+			items[i].level = para
+			trailing = true
+		case classWS, classBN:
+			if trailing {
+				items[i].level = para
+			}
+		default:
+			trailing = false
+		}
+	}
+}
+
+// resolveWeak applies the rules W1 to W7 to the types t.
+func resolveWeak(t []class, sos class) {
+	// W1: nonspacing marks take the type of the preceding rune
+	prev := sos
+	for i, c := range t {
+		if c == classNSM {
+			t[i] = prev
+		}
+		prev = t[i]
+	}
+
+	// W2: European numbers following Arabic letters become Arabic numbers,
+	// W3: Arabic letters become right-to-left
+	strong := sos
+	for i, c := range t {
+		switch c {
+		case classL, classR, classAL:
+			strong = c
+		case classEN:
+			if strong == classAL {
+				t[i] = classAN
+			}
+		}
+	}
+	for i, c := range t {
+		if c == classAL {
+			t[i] = classR
+		}
+	}
+
+	// W4: single separators between numbers of the same kind join them
+	for i := 1; i < len(t)-1; i++ {
+		switch {
+		case t[i] == classES && t[i-1] == classEN && t[i+1] == classEN:
+			t[i] = classEN
+		case t[i] == classCS && t[i-1] == classEN && t[i+1] == classEN:
+			t[i] = classEN
+		case t[i] == classCS && t[i-1] == classAN && t[i+1] == classAN:
+			t[i] = classAN
+		}
+	}
+
+	// W5: terminators adjacent to European numbers become numbers
+	for i := 0; i < len(t); i++ {
+		if t[i] != classET {
+			continue
+		}
+		j := i
+		for j < len(t) && t[j] == classET {
+			j++
+		}
+		if (i > 0 && t[i-1] == classEN) || (j < len(t) && t[j] == classEN) {
+			for k := i; k < j; k++ {
+				t[k] = classEN
+			}
+		}
+		i = j
+	}
+
+	// W6: remaining separators and terminators become neutral
+	for i, c := range t {
+		switch c {
+		case classES, classET, classCS:
+			t[i] = classON
+		}
+	}
+
+	// W7: European numbers following left-to-right text become
+	// left-to-right
+	strong = sos
+	for i, c := range t {
+		switch c {
+		case classL, classR:
+			strong = c
+		case classEN:
+			if strong == classL {
+				t[i] = classL
+			}
+		}
+	}
+}
+
+// resolveNeutral applies the rules N1 and N2 to the types t.
+func resolveNeutral(t []class, sos class) {
+	direction := func(c class) class {
+		switch c {
+		case classR, classEN, classAN:
+			return classR
+		}
+		return c
+	}
+	isNeutral := func(c class) bool {
+		switch c {
+		case classB, classS, classWS, classON:
+			return true
+		}
+		return false
+	}
+
+	for i := 0; i < len(t); i++ {
+		if !isNeutral(t[i]) {
+			continue
+		}
+		j := i
+		for j < len(t) && isNeutral(t[j]) {
+			j++
+		}
+
+		before, after := sos, sos
+		if i > 0 {
+			before = direction(t[i-1])
+		}
+		if j < len(t) {
+			after = direction(t[j])
+		}
+
+		// N1: neutrals between runs of the same direction take it on,
+		// N2: all others take the embedding direction
+		dir := sos
+		if before == after {
+			dir = before
+		}
+		for k := i; k < j; k++ {
+			t[k] = dir
+		}
+		i = j
+	}
+}
@@ -0,0 +1,106 @@
+package bidi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBidi(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input    string
+		Expected string
+	}{
+		// Left-to-right text passes through:
+		{
+			"foo (bar) 123",
+			"foo (bar) 123",
+		},
+		// Right-to-left text is reversed:
+		{
+			"אבג דהו",
+			"והד גבא",
+		},
+		// Right-to-left runs within left-to-right text:
+		{
+			"foo אבג bar",
+			"foo גבא bar",
+		},
+		// Numbers keep their order within right-to-left text:
+		{
+			"אבג 123 דה",
+			"הד 123 גבא",
+		},
+		// Left-to-right runs within right-to-left text:
+		{
+			"אבג foo bar.",
+			".foo bar גבא",
+		},
+		// Brackets are mirrored:
+		{
+			"א(ב)",
+			"(ב)א",
+		},
+		// Arabic numbers:
+		{
+			"عدد ١٢٣",
+			"١٢٣ ددع",
+		},
+		// Trailing whitespace stays at the end:
+		{
+			"foo אב  ",
+			"foo בא  ",
+		},
+		// Every line is reordered on its own:
+		{
+			"אב\nfoo\r\nגד\rהו",
+			"בא\nfoo\r\nדג\rוה",
+		},
+		// Styles stay attached to their runes:
+		{
+			"\x1b[31mאב\x1b[0m ג",
+			"ג \x1b[31mבא\x1b[0m",
+		},
+		// The style state at the end of the line is kept:
+		{
+			"א \x1b[1mב",
+			"\x1b[1mב\x1b[0m א\x1b[1m",
+		},
+		// Styles carry over to the following lines:
+		{
+			"\x1b[1mא\nב \x1b[0mג",
+			"\x1b[1mא\n\x1b[0mג\x1b[1m ב\x1b[0m",
+		},
+	}
+
+	for i, tc := range tt {
+		if s := String(tc.Input); s != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, s)
+		}
+	}
+}
+
+func TestBidiBytes(t *testing.T) {
+	t.Parallel()
+
+	actual := Bytes([]byte("אב"))
+	expected := []byte("בא")
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
+	}
+}
+
+func TestBidiReorder(t *testing.T) {
+	t.Parallel()
+
+	s, style := Reorder("\x1b[1mאב\n", "")
+	if s != "\x1b[1mבא\n" || style != "\x1b[1m" {
+		t.Errorf("unexpected result %q with style %q", s, style)
+	}
+
+	s, style = Reorder("גד\x1b[0m", style)
+	if s != "דג\x1b[0m" || style != "" {
+		t.Errorf("unexpected result %q with style %q", s, style)
+	}
+}
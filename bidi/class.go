@@ -0,0 +1,191 @@
+package bidi
+
+import "unicode"
+
+// class is a bidirectional character type, as defined by UAX #9.
+type class uint8
+
+const (
+	classL   class = iota // left-to-right
+	classR                // right-to-left
+	classAL               // Arabic letter
+	classEN               // European number
+	classES               // European separator
+	classET               // European terminator
+	classAN               // Arabic number
+	classCS               // common separator
+	classNSM              // nonspacing mark
+	classBN               // boundary neutral
+	classB                // paragraph separator
+	classS                // segment separator
+	classWS               // whitespace
+	classON               // other neutral
+)
+
+type classRange struct {
+	lo, hi rune
+	class  class
+}
+
+// classRanges lists the bidirectional types of runes deviating from the
+// defaults applied by lookup. Earlier entries take precedence.
+var classRanges = []classRange{
+	{0x0009, 0x0009, classS},
+	{0x000a, 0x000a, classB},
+	{0x000b, 0x000b, classS},
+	{0x000c, 0x000c, classWS},
+	{0x000d, 0x000d, classB},
+	{0x0000, 0x001b, classBN},
+	{0x001c, 0x001e, classB},
+	{0x001f, 0x001f, classS},
+	{0x0020, 0x0020, classWS},
+	{0x0023, 0x0025, classET},
+	{0x002b, 0x002b, classES},
+	{0x002c, 0x002c, classCS},
+	{0x002d, 0x002d, classES},
+	{0x002e, 0x002f, classCS},
+	{0x0030, 0x0039, classEN},
+	{0x003a, 0x003a, classCS},
+	{0x0085, 0x0085, classB},
+	{0x007f, 0x009f, classBN},
+	{0x00a0, 0x00a0, classCS},
+	{0x00a2, 0x00a5, classET},
+	{0x00ad, 0x00ad, classBN},
+	{0x00b0, 0x00b1, classET},
+	{0x00b2, 0x00b3, classEN},
+	{0x00b9, 0x00b9, classEN},
+
+	// Hebrew
+	{0x0591, 0x05bd, classNSM},
+	{0x05bf, 0x05bf, classNSM},
+	{0x05c1, 0x05c2, classNSM},
+	{0x05c4, 0x05c5, classNSM},
+	{0x05c7, 0x05c7, classNSM},
+	{0x0590, 0x05ff, classR},
+
+	// Arabic, Syriac, Thaana
+	{0x0600, 0x0605, classAN},
+	{0x0608, 0x0608, classAL},
+	{0x0609, 0x060a, classET},
+	{0x060b, 0x060b, classAL},
+	{0x060c, 0x060c, classCS},
+	{0x060d, 0x060d, classAL},
+	{0x0660, 0x0669, classAN},
+	{0x066a, 0x066a, classET},
+	{0x066b, 0x066c, classAN},
+	{0x06dd, 0x06dd, classAN},
+	{0x06f0, 0x06f9, classEN},
+	{0x0610, 0x061a, classNSM},
+	{0x064b, 0x065f, classNSM},
+	{0x0670, 0x0670, classNSM},
+	{0x06d6, 0x06dc, classNSM},
+	{0x06df, 0x06e4, classNSM},
+	{0x06e7, 0x06e8, classNSM},
+	{0x06ea, 0x06ed, classNSM},
+	{0x0600, 0x07bf, classAL},
+
+	// NKo, Samaritan, Mandaic
+	{0x07c0, 0x085f, classR},
+
+	// Arabic extended
+	{0x0860, 0x08ff, classAL},
+
+	{0x1680, 0x1680, classWS},
+	{0x2000, 0x200a, classWS},
+	{0x200b, 0x200d, classBN},
+	{0x200e, 0x200e, classL},
+	{0x200f, 0x200f, classR},
+	{0x2028, 0x2028, classWS},
+	{0x2029, 0x2029, classB},
+	// explicit formatting characters are ignored
+	{0x202a, 0x202e, classBN},
+	{0x202f, 0x202f, classCS},
+	{0x2030, 0x2034, classET},
+	{0x2044, 0x2044, classCS},
+	{0x205f, 0x205f, classWS},
+	{0x2060, 0x206f, classBN},
+	{0x2070, 0x2070, classEN},
+	{0x2074, 0x2079, classEN},
+	{0x207a, 0x207b, classES},
+	{0x2080, 0x2089, classEN},
+	{0x208a, 0x208b, classES},
+	{0x20a0, 0x20cf, classET},
+	{0x2212, 0x2212, classES},
+	{0x3000, 0x3000, classWS},
+
+	// Hebrew and Arabic presentation forms
+	{0xfb1e, 0xfb1e, classNSM},
+	{0xfb29, 0xfb29, classES},
+	{0xfb1d, 0xfb4f, classR},
+	{0xfd3e, 0xfd3f, classON},
+	{0xfb50, 0xfdff, classAL},
+	{0xfe50, 0xfe50, classCS},
+	{0xfe52, 0xfe52, classCS},
+	{0xfe55, 0xfe55, classCS},
+	{0xfe5f, 0xfe5f, classET},
+	{0xfe62, 0xfe63, classES},
+	{0xfe69, 0xfe6a, classET},
+	{0xfe70, 0xfefe, classAL},
+	{0xfeff, 0xfeff, classBN},
+	{0xff03, 0xff05, classET},
+	{0xff0b, 0xff0b, classES},
+	{0xff0c, 0xff0c, classCS},
+	{0xff0d, 0xff0d, classES},
+	{0xff0e, 0xff0f, classCS},
+	{0xff10, 0xff19, classEN},
+	{0xff1a, 0xff1a, classCS},
+	{0xffe0, 0xffe1, classET},
+	{0xffe5, 0xffe6, classET},
+
+	// historic right-to-left scripts
+	{0x10800, 0x10fff, classR},
+	{0x1e800, 0x1efff, classR},
+}
+
+// lookup returns the bidirectional type of r. Runes not covered by
+// classRanges are nonspacing marks, neutrals or left-to-right.
+func lookup(r rune) class {
+	for _, cr := range classRanges {
+		if r >= cr.lo && r <= cr.hi {
+			return cr.class
+		}
+	}
+
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return classNSM
+	case unicode.IsSpace(r):
+		return classWS
+	case unicode.IsPunct(r) || unicode.IsSymbol(r):
+		return classON
+	}
+	return classL
+}
+
+// mirror returns the mirrored glyph of brackets, as displayed within
+// right-to-left text.
+func mirror(r rune) rune {
+	switch r {
+	case '(':
+		return ')'
+	case ')':
+		return '('
+	case '[':
+		return ']'
+	case ']':
+		return '['
+	case '{':
+		return '}'
+	case '}':
+		return '{'
+	case '<':
+		return '>'
+	case '>':
+		return '<'
+	case '«':
+		return '»'
+	case '»':
+		return '«'
+	}
+	return r
+}
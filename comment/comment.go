@@ -0,0 +1,176 @@
+package comment
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+// markers are the recognised comment markers. Repeating their last rune, as
+// in "///" or ";;", still forms a marker.
+var markers = []string{"//", "--", "#", "*", ";", "%", "!"}
+
+// Writer contains settings and state for reflowing prefixed blocks of text,
+// such as source code comments or quoted emails. The prefix of every line is
+// detected and stripped, consecutive lines sharing a prefix get word-wrapped
+// as a paragraph and the prefix is then re-applied to every line.
+type Writer struct {
+	Limit int
+
+	in  bytes.Buffer
+	buf bytes.Buffer
+}
+
+// NewWriter returns a new instance of a comment-reflowing writer, initialized
+// with default settings.
+func NewWriter(limit int) *Writer {
+	return &Writer{
+		Limit: limit,
+	}
+}
+
+// Bytes is shorthand for declaring a new default comment-writer instance,
+// used to immediately reflow a byte slice.
+func Bytes(b []byte, limit int) []byte {
+	f := NewWriter(limit)
+	_, _ = f.Write(b)
+	_ = f.Close()
+
+	return f.Bytes()
+}
+
+// String is shorthand for declaring a new default comment-writer instance,
+// used to immediately reflow a string.
+func String(s string, limit int) string {
+	return string(Bytes([]byte(s), limit))
+}
+
+// Write is used to write more content to the comment buffer. As the prefix
+// can only be detected once all lines are known, nothing gets reflowed until
+// Close is called.
+func (w *Writer) Write(b []byte) (int, error) {
+	return w.in.Write(b)
+}
+
+// Close will finish the reflow operation. Always call it before trying to
+// retrieve the final result.
+func (w *Writer) Close() error {
+	s := w.in.String()
+	w.in.Reset()
+
+	if w.Limit <= 0 {
+		_, err := w.buf.WriteString(s)
+		return err
+	}
+
+	var out []string
+	var para []string
+	var prefix, eol string
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+
+		width := w.Limit - ansi.PrintableRuneWidth(prefix)
+		if width < 1 {
+			width = 1
+		}
+		wrapped := wordwrap.String(strings.Join(para, " "), width)
+		for _, l := range strings.Split(wrapped, "\n") {
+			out = append(out, prefix+l+eol)
+		}
+		para = para[:0]
+	}
+
+	for _, l := range strings.Split(s, "\n") {
+		text := strings.TrimSuffix(l, "\r")
+		p := linePrefix(text)
+		if len(p) == len(text) || delimiter(text) {
+			// blank and prefix-only lines separate paragraphs, while the
+			// lines opening and closing block comments stay as they are
+			flush()
+			out = append(out, l)
+			continue
+		}
+
+		if len(para) > 0 && p != prefix {
+			// a change of prefix starts a new paragraph
+			flush()
+		}
+		if len(para) == 0 {
+			prefix = p
+			eol = l[len(text):]
+		}
+		para = append(para, strings.TrimSpace(text[len(p):]))
+	}
+	flush()
+
+	_, err := w.buf.WriteString(strings.Join(out, "\n"))
+	return err
+}
+
+// Bytes returns the reflowed result as a byte slice.
+func (w *Writer) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// String returns the reflowed result as a string.
+func (w *Writer) String() string {
+	return w.buf.String()
+}
+
+// delimiter reports whether a line opens or closes a block comment, as in
+// "/*" or " */".
+func delimiter(s string) bool {
+	s = strings.TrimSpace(s)
+	return strings.HasPrefix(s, "/*") || strings.HasSuffix(s, "*/")
+}
+
+// linePrefix returns the leading prefix of a line: nested quote markers and a
+// comment marker, with any whitespace and escape sequences around them, e.g.
+// "// ", "# ", "-- ", " * ", "> > " or the indentation of "//   code". Markers other than "//" need to be
+// followed by whitespace, so text like "*emphasis*" isn't mistaken for one.
+func linePrefix(s string) string {
+	i := skip(s, 0)
+	for i < len(s) && s[i] == '>' {
+		i = skip(s, i+1)
+	}
+
+	for _, m := range markers {
+		if !strings.HasPrefix(s[i:], m) {
+			continue
+		}
+
+		j := i + len(m)
+		for j < len(s) && s[j] == m[len(m)-1] {
+			j++
+		}
+		if m == "//" || j == len(s) || s[j] == ' ' || s[j] == '\t' || s[j] == ansi.Marker {
+			i = skip(s, j)
+		}
+		break
+	}
+
+	return s[:i]
+}
+
+// skip returns the index of the first rune from i on that is neither
+// whitespace nor part of an escape sequence.
+func skip(s string, i int) int {
+	var esc bool
+	for j, c := range s[i:] {
+		switch {
+		case c == ansi.Marker:
+			esc = true
+		case esc:
+			if ansi.IsTerminator(c) {
+				esc = false
+			}
+		case c != ' ' && c != '\t':
+			return i + j
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,150 @@
+package comment
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestComment(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input    string
+		Expected string
+		Limit    int
+	}{
+		// No-op, should pass through:
+		{
+			"// foo bar\n// baz",
+			"// foo bar\n// baz",
+			0,
+		},
+		// Lines are joined and re-wrapped within the prefix:
+		{
+			"// foo bar\n// baz qux quux",
+			"// foo bar baz\n// qux quux",
+			14,
+		},
+		// Prefix-only lines separate paragraphs:
+		{
+			"# foo\n#\n# bar baz\n",
+			"# foo\n#\n# bar\n# baz\n",
+			6,
+		},
+		// Other common prefixes:
+		{
+			"-- foo bar",
+			"-- foo\n-- bar",
+			6,
+		},
+		{
+			" * foo bar\n * baz",
+			" * foo\n * bar\n * baz",
+			7,
+		},
+		// Nested quotes are detected as a whole:
+		{
+			"> > foo bar baz",
+			"> > foo\n> > bar\n> > baz",
+			8,
+		},
+		// Indentation following the prefix is kept:
+		{
+			"// see\n//   go test ./...\n// for more",
+			"// see\n//   go test\n//   ./...\n// for more",
+			12,
+		},
+		// Block comments keep their delimiter lines:
+		{
+			"/*\n * foo bar baz qux\n * quux\n */",
+			"/*\n * foo bar\n * baz qux\n * quux\n */",
+			10,
+		},
+		// Quotes of different depth form separate paragraphs:
+		{
+			"> outer\n> > inner text here",
+			"> outer\n> > inner\n> > text\n> > here",
+			12,
+		},
+		// CRLF line endings are kept:
+		{
+			"// foo bar\r\n// baz\r\n//\r\n",
+			"// foo\r\n// bar\r\n// baz\r\n//\r\n",
+			7,
+		},
+		// ANSI colored prefixes don't count toward the width:
+		{
+			"\x1B[2m//\x1B[0m foo bar\n\x1B[2m//\x1B[0m baz",
+			"\x1B[2m//\x1B[0m foo\n\x1B[2m//\x1B[0m bar\n\x1B[2m//\x1B[0m baz",
+			7,
+		},
+		// Text starting with marker-like runes isn't part of the prefix:
+		{
+			"// -1 means disabled here",
+			"// -1 means\n// disabled\n// here",
+			12,
+		},
+		{
+			"// - list item text",
+			"// - list\n// item text",
+			12,
+		},
+		{
+			"// *emphasis* matters here",
+			"// *emphasis*\n// matters\n// here",
+			12,
+		},
+		{
+			"# --verbose prints more",
+			"# --verbose\n# prints\n# more",
+			12,
+		},
+		{
+			"; #hashtag and more",
+			"; #hashtag\n; and more",
+			12,
+		},
+		// Lines without any text pass through:
+		{
+			"//\n//",
+			"//\n//",
+			4,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestCommentString(t *testing.T) {
+	t.Parallel()
+
+	actual := String("// foo bar", 6)
+	expected := "// foo\n// bar"
+	if actual != expected {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
+	}
+}
+
+func TestCommentBytes(t *testing.T) {
+	t.Parallel()
+
+	actual := Bytes([]byte("// foo bar"), 6)
+	expected := []byte("// foo\n// bar")
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
+	}
+}
@@ -0,0 +1,75 @@
+package wrapping
+
+import (
+	"io/ioutil"
+	"strings"
+	"unicode"
+
+	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/truncate"
+)
+
+// Clamp caps wrapped output at its last line. Content following that line
+// gets dropped, and the line gets cut off to put a tail on its end instead.
+// It implements the MaxLines of the wrapping writers.
+type Clamp struct {
+	styles    *ansi.Writer
+	ansi      bool
+	truncated bool
+}
+
+// Track keeps track of the styles active at the end of b, which makes up part
+// of the output.
+func (c *Clamp) Track(b []byte) {
+	if c.styles == nil {
+		c.styles = &ansi.Writer{Forward: ioutil.Discard}
+	}
+	_, _ = c.styles.Write(b)
+}
+
+// Drop discards a rune following the last line. It reports whether the rune
+// is the first visible content to be dropped, in which case the last line
+// needs to be cut off, see Line.
+func (c *Clamp) Drop(r rune) bool {
+	switch {
+	case r == ansi.Marker:
+		c.ansi = true
+	case c.ansi:
+		if ansi.IsTerminator(r) {
+			c.ansi = false
+		}
+	case !c.truncated && !unicode.IsSpace(r):
+		return true
+	}
+	return false
+}
+
+// Line cuts off line, the last line of the output, so that it fits width
+// along with tail, which gets put on its end. Trailing whitespace gets
+// dropped, and any styles left open get reset after the tail. All output
+// preceding line must have been tracked, see Track.
+func (c *Clamp) Line(line string, width int, tail string) string {
+	c.truncated = true
+
+	line = strings.TrimRightFunc(line, unicode.IsSpace)
+	room := width - ansi.PrintableRuneWidth(tail)
+	if room < 0 {
+		room = 0
+	}
+	if ansi.PrintableRuneWidth(line) > room {
+		line = strings.TrimRightFunc(truncate.String(line, uint(room)), unicode.IsSpace)
+	}
+	line += tail
+
+	// close any sequences still open
+	c.Track([]byte(line))
+	if c.styles.LastSequence() != "" {
+		line += "\x1b[0m"
+	}
+	return line
+}
+
+// Truncated reports whether any content got dropped.
+func (c *Clamp) Truncated() bool {
+	return c.truncated
+}
@@ -0,0 +1,64 @@
+// Package wrapping holds the state shared by the wrapping writers of the
+// wordwrap and wrap packages.
+package wrapping
+
+import (
+	"strings"
+)
+
+// Endings keeps track of the line endings found in the input of a wrapping
+// writer, so line breaks it adds can follow them.
+type Endings struct {
+	// Last is the line ending of the last input line that ended.
+	Last string
+
+	current string
+	inLine  bool
+}
+
+// LookAhead determines the line ending of the current input line from s,
+// which continues it. start reports whether s is the start of a Write, as
+// a line ending not found before may follow. Any rune in newline ends a
+// line.
+func (e *Endings) LookAhead(s string, start bool, newline []rune) {
+	if e.inLine && (!start || e.current != "") {
+		return
+	}
+
+	e.current = lineEnding(s, newline)
+	e.inLine = true
+}
+
+// EndLine ends the current input line.
+func (e *Endings) EndLine() {
+	e.current = ""
+	e.inLine = false
+}
+
+// Preserved returns the line ending of the current input line if it's
+// known, or else the last one found. It returns an empty string if no line
+// ending has been found yet.
+func (e *Endings) Preserved() string {
+	if e.current != "" {
+		return e.current
+	}
+	return e.Last
+}
+
+// lineEnding returns the line ending terminating the first line of s, or an
+// empty string if s doesn't contain it entirely.
+func lineEnding(s string, newline []rune) string {
+	for i, c := range s {
+		if c == '\r' && strings.HasPrefix(s[i+1:], "\n") {
+			return "\r\n"
+		}
+		if strings.ContainsRune(string(newline), c) {
+			if c == '\r' && i+1 == len(s) {
+				// might be the start of a CRLF line ending
+				return ""
+			}
+			return string(c)
+		}
+	}
+	return ""
+}
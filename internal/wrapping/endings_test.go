@@ -0,0 +1,38 @@
+package wrapping
+
+import (
+	"testing"
+)
+
+func TestEndings(t *testing.T) {
+	t.Parallel()
+
+	newline := []rune{'\n', '\r'}
+	tt := []struct {
+		Input    []string
+		Last     string
+		Expected string
+	}{
+		// the line ending of the current line
+		{[]string{"foo\r\nbar\n"}, "", "\r\n"},
+		{[]string{"foo\rbar\n"}, "", "\r"},
+		// a CR might be the start of a CRLF line ending
+		{[]string{"foo\r"}, "\n", "\n"},
+		// found with the next write
+		{[]string{"foo", "bar\r\n"}, "", "\r\n"},
+		// no line ending found
+		{[]string{"foo"}, "\r\n", "\r\n"},
+		{[]string{"foo"}, "", ""},
+	}
+
+	for i, tc := range tt {
+		e := &Endings{Last: tc.Last}
+		for _, s := range tc.Input {
+			e.LookAhead(s, true, newline)
+		}
+
+		if actual := e.Preserved(); actual != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, actual)
+		}
+	}
+}
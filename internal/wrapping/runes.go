@@ -0,0 +1,38 @@
+package wrapping
+
+import (
+	"unicode/utf8"
+)
+
+// Runes holds back an incomplete rune at the end of a Write, so runes split
+// across writes don't get mangled.
+type Runes struct {
+	partial []byte
+}
+
+// Complete prefixes b with the incomplete rune held back by the previous
+// Write, if any, and holds back an incomplete rune at the end of b in turn.
+func (r *Runes) Complete(b []byte) []byte {
+	if len(r.partial) > 0 {
+		b = append(r.partial, b...)
+		r.partial = nil
+	}
+
+	i := len(b) - 1
+	for i > 0 && len(b)-i < utf8.UTFMax && !utf8.RuneStart(b[i]) {
+		i--
+	}
+	if i >= 0 && !utf8.FullRune(b[i:]) {
+		r.partial = append([]byte(nil), b[i:]...)
+		b = b[:i]
+	}
+	return b
+}
+
+// Rest returns the incomplete rune still held back at the end of the input,
+// if any, which is no longer held back afterwards.
+func (r *Runes) Rest() []byte {
+	b := r.partial
+	r.partial = nil
+	return b
+}
@@ -0,0 +1,38 @@
+package wrapping
+
+import (
+	"testing"
+)
+
+func TestRunes(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input    []string
+		Expected string
+		Rest     string
+	}{
+		// runes split across writes get completed
+		{[]string{"abc\xe4", "\xb8\xad"}, "abc\xe4\xb8\xad", ""},
+		{[]string{"abc\xe4", "\xb8", "\xad def"}, "abc\xe4\xb8\xad def", ""},
+		// an incomplete rune at the end is held back
+		{[]string{"abc\xe4\xb8"}, "abc", "\xe4\xb8"},
+		// invalid bytes aren't held back
+		{[]string{"abc\xff"}, "abc\xff", ""},
+	}
+
+	for i, tc := range tt {
+		r := &Runes{}
+		var actual string
+		for _, s := range tc.Input {
+			actual += string(r.Complete([]byte(s)))
+		}
+
+		if actual != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, actual)
+		}
+		if rest := string(r.Rest()); rest != tc.Rest {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Rest, rest)
+		}
+	}
+}
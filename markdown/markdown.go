@@ -0,0 +1,432 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/muesli/reflow/ansi"
+)
+
+var (
+	quoteRe     = regexp.MustCompile(`^( {0,3}> ?)+`)
+	headingRe   = regexp.MustCompile(`^#{1,6}(\s|$)`)
+	fenceRe     = regexp.MustCompile("^(`{3,}|~{3,})")
+	breakRe     = regexp.MustCompile(`^((\*\s*){3,}|(-\s*){3,}|(_\s*){3,})$`)
+	setextRe    = regexp.MustCompile(`^(=+|-+)\s*$`)
+	listRe      = regexp.MustCompile(`^([-*+]|\d{1,9}[.)])( {1,4}|\t|$)`)
+	htmlRe      = regexp.MustCompile(`^<[A-Za-z/!?]`)
+	delimiterRe = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// Writer contains settings and state for reflowing markdown documents. Only
+// paragraph text gets re-wrapped: headings, code blocks, tables and HTML
+// blocks pass through unchanged, while blockquote and list prefixes are kept
+// on every wrapped line.
+type Writer struct {
+	Limit int
+
+	in  bytes.Buffer
+	buf bytes.Buffer
+
+	// currently open paragraph
+	open  bool
+	raw   []string
+	text  []string
+	quote string
+	first string
+	cont  string
+
+	fence      string
+	html       bool
+	table      bool
+	listIndent int
+
+	// line ending of the current input line
+	eol string
+}
+
+// NewWriter returns a new instance of a markdown-reflowing writer, initialized
+// with default settings.
+func NewWriter(limit int) *Writer {
+	return &Writer{
+		Limit: limit,
+	}
+}
+
+// Bytes is shorthand for declaring a new default markdown-writer instance,
+// used to immediately reflow a byte slice.
+func Bytes(b []byte, limit int) []byte {
+	f := NewWriter(limit)
+	_, _ = f.Write(b)
+	_ = f.Close()
+
+	return f.Bytes()
+}
+
+// String is shorthand for declaring a new default markdown-writer instance,
+// used to immediately reflow a string.
+func String(s string, limit int) string {
+	return string(Bytes([]byte(s), limit))
+}
+
+// Write is used to write more content to the markdown buffer. As block
+// structure depends on the whole document, nothing gets reflowed until Close
+// is called.
+func (w *Writer) Write(b []byte) (int, error) {
+	return w.in.Write(b)
+}
+
+// Close will finish the reflow operation. Always call it before trying to
+// retrieve the final result.
+func (w *Writer) Close() error {
+	if w.Limit <= 0 {
+		_, err := w.in.WriteTo(&w.buf)
+		return err
+	}
+
+	lines := strings.Split(w.in.String(), "\n")
+	w.in.Reset()
+
+	w.eol = "\n"
+	for i, line := range lines {
+		// the last line has no ending of its own and keeps the previous one
+		if i < len(lines)-1 {
+			w.eol = "\n"
+			if strings.HasSuffix(line, "\r") {
+				line = line[:len(line)-1]
+				w.eol = "\r\n"
+			}
+		}
+		w.line(line)
+	}
+	w.flush()
+
+	// every emitted line got terminated, the input's last line was not
+	w.buf.Truncate(w.buf.Len() - len(w.eol))
+	return nil
+}
+
+// Bytes returns the reflowed result as a byte slice.
+func (w *Writer) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// String returns the reflowed result as a string.
+func (w *Writer) String() string {
+	return w.buf.String()
+}
+
+func (w *Writer) emit(s string) {
+	_, _ = w.buf.WriteString(s)
+	_, _ = w.buf.WriteString(w.eol)
+}
+
+func (w *Writer) line(line string) {
+	quote := quoteRe.FindString(line)
+	rest := line[len(quote):]
+
+	if w.fence != "" {
+		w.emit(line)
+		if strings.HasPrefix(strings.TrimLeft(rest, " "), w.fence) {
+			w.fence = ""
+		}
+		return
+	}
+
+	if strings.TrimSpace(rest) == "" {
+		w.flush()
+		w.emit(line)
+		w.html = false
+		w.table = false
+		return
+	}
+	if w.html {
+		w.emit(line)
+		return
+	}
+
+	// a differently nested blockquote starts a new paragraph, while lines
+	// without any quote prefix lazily continue the current one
+	if w.open && quote != "" && quote != w.quote {
+		w.flush()
+	}
+
+	container := quote
+	indent := indentWidth(rest)
+	if w.listIndent > 0 {
+		if indent >= w.listIndent {
+			container += strings.Repeat(" ", w.listIndent)
+			rest = stripIndent(rest, w.listIndent)
+			indent -= w.listIndent
+		} else if !w.open && !listRe.MatchString(strings.TrimLeft(rest, " \t")) {
+			w.listIndent = 0
+		}
+	}
+	inner := strings.TrimLeft(rest, " \t")
+
+	switch {
+	case indent >= 4 && !w.open:
+		// indented code block
+		w.emit(line)
+
+	case indent >= 4:
+		w.append(line, inner)
+
+	case fenceRe.MatchString(inner):
+		w.flush()
+		w.fence = fenceRe.FindString(inner)
+		w.emit(line)
+
+	case headingRe.MatchString(inner):
+		w.flush()
+		w.emit(line)
+
+	case w.open && setextRe.MatchString(inner):
+		// the paragraph turned out to be a heading
+		w.verbatim()
+		w.emit(line)
+
+	case breakRe.MatchString(inner):
+		w.flush()
+		w.emit(line)
+
+	case w.table && strings.Contains(inner, "|"),
+		strings.HasPrefix(inner, "|"):
+		w.flush()
+		w.table = true
+		w.emit(line)
+
+	case strings.Contains(inner, "|") && delimiterRe.MatchString(inner):
+		// a delimiter row turns the preceding line into a table header
+		if w.open && len(w.raw) == 1 && strings.Contains(w.raw[0], "|") {
+			w.verbatim()
+		}
+		w.flush()
+		w.table = true
+		w.emit(line)
+
+	case htmlRe.MatchString(inner):
+		w.flush()
+		w.html = true
+		w.emit(line)
+
+	case listRe.MatchString(inner):
+		w.flush()
+		marker := listRe.FindString(inner)
+		lead := strings.Repeat(" ", indent)
+		first := marker
+		markerWidth := len(marker)
+		if strings.TrimRight(marker, " \t") == marker {
+			// list item without any content following its marker, which
+			// still needs to be separated from content continuing it
+			first += " "
+			markerWidth++
+		}
+
+		w.listIndent = len(container) - len(quote) + indent + markerWidth
+		w.start(quote, container+lead+first, container+strings.Repeat(" ", indent+markerWidth))
+		w.append(line, inner[len(marker):])
+
+	default:
+		if !w.open {
+			lead := strings.Repeat(" ", indent)
+			w.start(quote, container+lead, container+lead)
+		}
+		w.append(line, inner)
+	}
+}
+
+// start opens a new paragraph with the given line prefixes.
+func (w *Writer) start(quote, first, cont string) {
+	w.open = true
+	w.quote = quote
+	w.first = first
+	w.cont = cont
+}
+
+// append adds a line of text to the currently open paragraph.
+func (w *Writer) append(line, text string) {
+	w.raw = append(w.raw, line)
+	w.text = append(w.text, text)
+
+	// a hard line break ends the current line, but not the paragraph
+	if strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\") {
+		hard := strings.HasSuffix(line, "  ")
+		w.wrap()
+		if hard {
+			w.buf.Truncate(w.buf.Len() - len(w.eol))
+			w.emit("  ")
+		}
+		w.first = w.cont
+	}
+}
+
+// verbatim emits the raw lines of the current paragraph unchanged.
+func (w *Writer) verbatim() {
+	for _, l := range w.raw {
+		w.emit(l)
+	}
+	w.reset()
+}
+
+// flush reflows and emits the current paragraph, if any.
+func (w *Writer) flush() {
+	if !w.open {
+		return
+	}
+	w.wrap()
+	w.reset()
+}
+
+func (w *Writer) reset() {
+	w.open = false
+	w.raw = w.raw[:0]
+	w.text = w.text[:0]
+}
+
+// wrap fills the paragraph text into as few lines as the limit allows.
+func (w *Writer) wrap() {
+	if len(w.text) == 0 {
+		return
+	}
+
+	prefix := w.first
+	var line strings.Builder
+	var lineLen int
+
+	for _, word := range words(strings.Join(w.text, " ")) {
+		wl := ansi.PrintableRuneWidth(word)
+		width := w.Limit - ansi.PrintableRuneWidth(prefix)
+
+		if lineLen > 0 && lineLen+1+wl > width {
+			w.emit(prefix + line.String())
+			prefix = w.cont
+			line.Reset()
+			lineLen = 0
+		}
+		if lineLen > 0 {
+			_ = line.WriteByte(' ')
+			lineLen++
+		}
+		_, _ = line.WriteString(word)
+		lineLen += wl
+	}
+
+	if lineLen > 0 {
+		w.emit(prefix + line.String())
+	} else {
+		w.emit(strings.TrimRight(prefix, " "))
+	}
+
+	w.raw = w.raw[:0]
+	w.text = w.text[:0]
+}
+
+// words splits paragraph text at whitespace, keeping code spans and link
+// destinations intact.
+func words(s string) []string {
+	var (
+		words []string
+		word  strings.Builder
+		ticks int // length of the backtick run opening a code span
+		depth int // parenthesis depth within a link destination
+	)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '`':
+			n := 1
+			for i+n < len(s) && s[i+n] == '`' {
+				n++
+			}
+			if ticks == 0 && closingTicks(s[i+n:], n) {
+				ticks = n
+			} else if ticks == n {
+				ticks = 0
+			}
+			_, _ = word.WriteString(s[i : i+n])
+			i += n - 1
+			continue
+		case ticks > 0:
+		case c == '\\' && i+1 < len(s):
+			_ = word.WriteByte(c)
+			i++
+			c = s[i]
+		case c == '(' && depth == 0:
+			if i > 0 && s[i-1] == ']' && strings.IndexByte(s[i:], ')') >= 0 {
+				depth = 1
+			}
+		case c == '(' && depth > 0:
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case (c == ' ' || c == '\t') && depth == 0:
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+
+		_ = word.WriteByte(c)
+	}
+
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// closingTicks reports whether s contains a backtick run of exactly n.
+func closingTicks(s string, n int) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '`' {
+			continue
+		}
+		j := i
+		for j < len(s) && s[j] == '`' {
+			j++
+		}
+		if j-i == n {
+			return true
+		}
+		i = j
+	}
+	return false
+}
+
+// indentWidth returns the width of the leading whitespace, expanding tabs to
+// the next multiple of four.
+func indentWidth(s string) int {
+	var n int
+	for _, c := range s {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4 - n%4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// stripIndent removes up to n columns of leading whitespace.
+func stripIndent(s string, n int) string {
+	var col int
+	for i, c := range s {
+		if col >= n || (c != ' ' && c != '\t') {
+			return s[i:]
+		}
+		if c == '\t' {
+			col += 4 - col%4
+		} else {
+			col++
+		}
+	}
+	return ""
+}
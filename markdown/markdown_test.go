@@ -0,0 +1,136 @@
+package markdown
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input    string
+		Expected string
+		Limit    int
+	}{
+		// No-op, should pass through:
+		{
+			"foo bar\nbaz",
+			"foo bar\nbaz",
+			0,
+		},
+		// Paragraphs are joined and re-wrapped:
+		{
+			"foo bar\nbaz qux\n\nquux",
+			"foo bar baz\nqux\n\nquux",
+			11,
+		},
+		// CRLF line endings are kept out of joined lines:
+		{
+			"foo bar\r\nbaz qux\r\n",
+			"foo bar baz qux\r\n",
+			40,
+		},
+		{
+			"foo bar\r\nbaz qux\r\n\r\nquux",
+			"foo bar baz\r\nqux\r\n\r\nquux",
+			11,
+		},
+		// Code spans and link destinations are never broken:
+		{
+			"see `foo bar` and [x](http://a.b/c-d \"e f\")",
+			"see\n`foo bar`\nand\n[x](http://a.b/c-d \"e f\")",
+			5,
+		},
+		// Unclosed code spans are literal backticks:
+		{
+			"a `b c",
+			"a `b\nc",
+			4,
+		},
+		// Headings, fences and indented code pass through:
+		{
+			"# foo bar baz\n\n```\nfoo bar baz\n```\n\n    foo bar baz\n",
+			"# foo bar baz\n\n```\nfoo bar baz\n```\n\n    foo bar baz\n",
+			4,
+		},
+		// Tables and HTML blocks pass through:
+		{
+			"a | b\n--|--\nfoo bar | baz\n\n<p>\nfoo bar baz\n</p>",
+			"a | b\n--|--\nfoo bar | baz\n\n<p>\nfoo bar baz\n</p>",
+			4,
+		},
+		// Setext headings pass through:
+		{
+			"foo bar baz\n---",
+			"foo bar baz\n---",
+			4,
+		},
+		// List items are not joined and keep their indentation:
+		{
+			"- foo bar baz\n- qux\n  quux\n\n  foo bar",
+			"- foo bar\n  baz\n- qux quux\n\n  foo bar",
+			10,
+		},
+		// Content continuing an empty list item stays separated from its marker:
+		{
+			"-\n  foo bar baz\n\n-\n\n- qux",
+			"- foo\n  bar\n  baz\n\n-\n\n- qux",
+			6,
+		},
+		// Ordered list items:
+		{
+			"10. foo bar baz",
+			"10. foo\n    bar\n    baz",
+			8,
+		},
+		// Blockquote prefixes are kept on every line:
+		{
+			"> foo bar baz\n> > qux quux",
+			"> foo bar\n> baz\n> > qux\n> > quux",
+			9,
+		},
+		// Hard line breaks are preserved:
+		{
+			"foo  \nbar baz\\\nqux",
+			"foo  \nbar baz\\\nqux",
+			20,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestMarkdownString(t *testing.T) {
+	t.Parallel()
+
+	actual := String("foo bar", 3)
+	expected := "foo\nbar"
+	if actual != expected {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
+	}
+}
+
+func TestMarkdownBytes(t *testing.T) {
+	t.Parallel()
+
+	actual := Bytes([]byte("foo bar"), 3)
+	expected := []byte("foo\nbar")
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
+	}
+}
@@ -11,15 +11,42 @@ import (
 
 type PaddingFunc func(w io.Writer)
 
+// Placement describes where blank lines are added to reach the height.
+type Placement int
+
+// Available placements.
+const (
+	// PadBottom adds blank lines below the content.
+	PadBottom Placement = iota
+	// PadTop adds blank lines above the content.
+	PadTop
+	// PadSplit adds blank lines around the content, keeping it in the
+	// middle. The odd line goes below.
+	PadSplit
+)
+
 type Writer struct {
 	Padding uint
 	PadFunc PaddingFunc
 
+	// Height pads blocks to a number of lines. Blank lines are filled up to
+	// the width of the block. As the line count needs to be known, content
+	// is held back until the Writer gets flushed.
+	Height    uint
+	Placement Placement
+	// Clip cuts off lines beyond the height. If Overflow is set, it replaces
+	// the last line to indicate that lines got cut off.
+	Clip     bool
+	Overflow string
+
 	ansiWriter *ansi.Writer
 	buf        bytes.Buffer
 	cache      bytes.Buffer
 	lineLen    int
 	ansi       bool
+
+	block   bytes.Buffer
+	forward io.Writer
 }
 
 func NewWriter(width uint, paddingFunc PaddingFunc) *Writer {
@@ -61,6 +88,8 @@ func String(s string, width uint) string {
 
 // Write is used to write content to the padding buffer.
 func (w *Writer) Write(b []byte) (int, error) {
+	w.hold()
+
 	for _, c := range string(b) {
 		if c == '\x1B' {
 			// ANSI escape sequence
@@ -110,6 +139,111 @@ func (w *Writer) pad() error {
 	return nil
 }
 
+// hold redirects the output to the block buffer if a height is set.
+func (w *Writer) hold() {
+	if w.Height > 0 && w.forward == nil {
+		w.forward = w.ansiWriter.Forward
+		w.ansiWriter.Forward = &w.block
+	}
+}
+
+// writeBlock pads or clips the held back block to the height and writes it
+// out.
+func (w *Writer) writeBlock() error {
+	defer func() {
+		w.block.Reset()
+		w.ansiWriter.Forward = w.forward
+		w.forward = nil
+	}()
+
+	var lines []string
+	var trailing bool
+	if w.block.Len() > 0 {
+		lines = strings.Split(w.block.String(), "\n")
+		if trailing = lines[len(lines)-1] == ""; trailing {
+			lines = lines[:len(lines)-1]
+		}
+	}
+
+	width := int(w.Padding)
+	for _, l := range lines {
+		if lw := ansi.PrintableRuneWidth(l); lw > width {
+			width = lw
+		}
+	}
+
+	height := int(w.Height)
+	overflow := -1
+	if len(lines) > height && w.Clip {
+		lines = lines[:height]
+		if w.Overflow != "" {
+			overflow = height - 1
+			lines[overflow] = w.Overflow
+		}
+	}
+
+	var top, bottom int
+	if extra := height - len(lines); extra > 0 {
+		switch w.Placement {
+		case PadBottom:
+			bottom = extra
+		case PadTop:
+			top = extra
+		case PadSplit:
+			top = extra / 2
+			bottom = extra - top
+		}
+	}
+
+	for i := 0; i < top+len(lines)+bottom; i++ {
+		if i > 0 {
+			if _, err := w.forward.Write([]byte{'\n'}); err != nil {
+				return err
+			}
+		}
+
+		if i < top || i >= top+len(lines) {
+			if err := w.fill(width); err != nil {
+				return err
+			}
+			continue
+		}
+
+		l := lines[i-top]
+		if _, err := io.WriteString(w.forward, l); err != nil {
+			return err
+		}
+		if i-top == overflow {
+			if err := w.fill(width - ansi.PrintableRuneWidth(l)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if trailing {
+		_, err := w.forward.Write([]byte{'\n'})
+		return err
+	}
+	return nil
+}
+
+// fill writes n cells of padding to the forward writer.
+func (w *Writer) fill(n int) error {
+	if n <= 0 {
+		return nil
+	}
+
+	if w.PadFunc != nil {
+		for i := 0; i < n; i++ {
+			w.PadFunc(w.forward)
+		}
+		return nil
+	}
+
+	_, err := io.WriteString(w.forward, strings.Repeat(" ", n))
+	return err
+}
+
 // Close will finish the padding operation.
 func (w *Writer) Close() (err error) {
 	return w.Flush()
@@ -128,12 +262,20 @@ func (w *Writer) String() string {
 // Flush will finish the padding operation. Always call it before trying to
 // retrieve the final result.
 func (w *Writer) Flush() (err error) {
+	w.hold()
+
 	if w.lineLen != 0 {
 		if err = w.pad(); err != nil {
 			return
 		}
 	}
 
+	if w.forward != nil {
+		if err = w.writeBlock(); err != nil {
+			return
+		}
+	}
+
 	w.cache.Reset()
 	_, err = w.buf.WriteTo(&w.cache)
 	w.lineLen = 0
@@ -67,6 +67,131 @@ func TestPadding(t *testing.T) {
 	}
 }
 
+func TestPaddingHeight(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input     string
+		Expected  string
+		Height    uint
+		Placement Placement
+		Clip      bool
+	}{
+		// Blank lines are added at the bottom per default:
+		{
+			"foo\nbar",
+			"foo \nbar \n    \n    ",
+			4,
+			PadBottom,
+			false,
+		},
+		{
+			"foo\nbar",
+			"    \n    \nfoo \nbar ",
+			4,
+			PadTop,
+			false,
+		},
+		// The odd blank line goes below:
+		{
+			"foo",
+			"    \nfoo \n    \n    ",
+			4,
+			PadSplit,
+			false,
+		},
+		// Trailing newlines are kept:
+		{
+			"foo\n",
+			"foo \n    \n",
+			2,
+			PadBottom,
+			false,
+		},
+		// Blank lines are as wide as the block:
+		{
+			"foobar",
+			"foobar\n      ",
+			2,
+			PadBottom,
+			false,
+		},
+		// Empty blocks:
+		{
+			"",
+			"    \n    ",
+			2,
+			PadBottom,
+			false,
+		},
+		// Taller blocks are left alone:
+		{
+			"foo\nbar\nbaz",
+			"foo \nbar \nbaz ",
+			2,
+			PadBottom,
+			false,
+		},
+		// Unless they get clipped:
+		{
+			"foo\nbar\nbaz",
+			"foo \n... ",
+			2,
+			PadBottom,
+			true,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(4, nil)
+		f.Height = tc.Height
+		f.Placement = tc.Placement
+		f.Clip = tc.Clip
+		f.Overflow = "..."
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if err := f.Close(); err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestPaddingHeightPadFunc(t *testing.T) {
+	t.Parallel()
+
+	b := &bytes.Buffer{}
+	f := NewWriterPipe(b, 4, func(w io.Writer) {
+		_, _ = w.Write([]byte("."))
+	})
+	f.Height = 3
+	f.Placement = PadSplit
+
+	if _, err := f.Write([]byte("foo")); err != nil {
+		t.Error(err)
+	}
+	if b.Len() > 0 {
+		t.Errorf("expected content to be held back, got `%s`", b.String())
+	}
+	if err := f.Close(); err != nil {
+		t.Error(err)
+	}
+
+	actual := b.String()
+	expected := "....\nfoo.\n...."
+
+	if actual != expected {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
+	}
+}
+
 func TestPaddingWriter(t *testing.T) {
 	t.Parallel()
 
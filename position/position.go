@@ -0,0 +1,150 @@
+// Package position maps byte offsets of a source text to rows and columns of
+// its wrapped output, and back again.
+package position
+
+import (
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+
+	"github.com/muesli/reflow/ansi"
+)
+
+// Line describes a single line of wrapped output, as recorded by the wrapping
+// writers.
+type Line struct {
+	// SourceStart and SourceEnd are the byte offsets of the source text
+	// making up the line. Whitespace dropped at line breaks and line endings
+	// belong to no line.
+	SourceStart int
+	SourceEnd   int
+
+	// OutputStart and OutputEnd are the byte offsets of the line within the
+	// output, excluding its line ending.
+	OutputStart int
+	OutputEnd   int
+
+	// Width is the visible width of the line in cells.
+	Width int
+
+	// Anchors tie source offsets of the line to the columns they are
+	// displayed at, as the output may differ from the source, e.g. due to
+	// expanded tabs, markers, indentation or justified gaps. Without any
+	// anchors, the line's source is displayed as is from column 0.
+	Anchors []Anchor
+}
+
+// Anchor ties the byte at offset Source of the source text to the column (in
+// cells) Col it is displayed at. The source text following it, up to the
+// next anchor of the line, is displayed unchanged.
+type Anchor struct {
+	Source int
+	Col    int
+}
+
+// anchors returns the anchors of l, defaulting to its source displayed as
+// is from column 0.
+func (l Line) anchors() []Anchor {
+	if len(l.Anchors) == 0 {
+		return []Anchor{{Source: l.SourceStart}}
+	}
+	return l.Anchors
+}
+
+// SourceToVisual returns the row and column (in cells) at which the byte at
+// offset of src is displayed. Offsets between lines, such as dropped
+// whitespace or line endings, map to the end of the preceding line.
+func SourceToVisual(src []byte, lines []Line, offset int) (row, col int) {
+	if len(lines) == 0 {
+		return 0, 0
+	}
+
+	for row < len(lines)-1 && lines[row+1].SourceStart <= offset {
+		row++
+	}
+
+	l := lines[row]
+	anchors := l.anchors()
+	if offset < l.SourceStart {
+		return row, anchors[0].Col
+	}
+	if offset > l.SourceEnd {
+		offset = l.SourceEnd
+	}
+
+	a := anchors[0]
+	for _, b := range anchors[1:] {
+		if b.Source > offset {
+			break
+		}
+		a = b
+	}
+	if offset < a.Source {
+		return row, a.Col
+	}
+	return row, a.Col + ansi.PrintableRuneWidth(string(src[a.Source:offset]))
+}
+
+// VisualToSource returns the byte offset of src displayed at the given row
+// and column (in cells), skipping any escape sequences. Positions beyond the
+// end of a line map to the line's end.
+func VisualToSource(src []byte, lines []Line, row, col int) int {
+	if len(lines) == 0 {
+		return 0
+	}
+	if row < 0 {
+		return lines[0].SourceStart
+	}
+	if row >= len(lines) {
+		return lines[len(lines)-1].SourceEnd
+	}
+
+	l := lines[row]
+	anchors := l.anchors()
+	k := 0
+	for k < len(anchors)-1 && anchors[k+1].Col <= col {
+		k++
+	}
+	if col < anchors[k].Col {
+		// in front of the line's source, like a marker or indentation
+		return anchors[k].Source
+	}
+
+	end := l.SourceEnd
+	if k < len(anchors)-1 {
+		end = anchors[k+1].Source
+	}
+
+	var (
+		width = anchors[k].Col
+		esc   bool
+		tab   = -1
+	)
+	for i := anchors[k].Source; i < end; {
+		c, size := utf8.DecodeRune(src[i:])
+
+		switch {
+		case c == ansi.Marker:
+			esc = true
+		case esc:
+			if ansi.IsTerminator(c) {
+				esc = false
+			}
+		case c == '\t':
+			tab = i
+		default:
+			tab = -1
+			width += runewidth.RuneWidth(c)
+			if width > col {
+				return i
+			}
+		}
+		i += size
+	}
+
+	if tab >= 0 && k < len(anchors)-1 {
+		// within the cells a tab got expanded to
+		return tab
+	}
+	return end
+}
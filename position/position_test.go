@@ -0,0 +1,113 @@
+package position
+
+import (
+	"testing"
+)
+
+// "foo bar\x1b[1mbaz\x1b[0m qux" word-wrapped at a limit of 5
+var (
+	src   = []byte("foo bar\x1b[1mbaz\x1b[0m qux")
+	lines = []Line{
+		{SourceStart: 0, SourceEnd: 3, OutputStart: 0, OutputEnd: 3, Width: 3},
+		{SourceStart: 4, SourceEnd: 18, OutputStart: 4, OutputEnd: 18, Width: 6},
+		{SourceStart: 19, SourceEnd: 22, OutputStart: 19, OutputEnd: 22, Width: 3},
+	}
+)
+
+func TestSourceToVisual(t *testing.T) {
+	tt := []struct {
+		Offset int
+		Row    int
+		Col    int
+	}{
+		{0, 0, 0},
+		{2, 0, 2},
+		// dropped whitespace
+		{3, 0, 3},
+		{4, 1, 0},
+		// escape sequences take up no columns
+		{11, 1, 3},
+		{14, 1, 6},
+		{19, 2, 0},
+		{21, 2, 2},
+		// beyond the end
+		{30, 2, 3},
+	}
+
+	for i, tc := range tt {
+		row, col := SourceToVisual(src, lines, tc.Offset)
+		if row != tc.Row || col != tc.Col {
+			t.Errorf("Test %d, expected:\n\n`%d:%d`\n\nActual Output:\n\n`%d:%d`", i, tc.Row, tc.Col, row, col)
+		}
+	}
+}
+
+func TestVisualToSource(t *testing.T) {
+	tt := []struct {
+		Row    int
+		Col    int
+		Offset int
+	}{
+		{0, 0, 0},
+		{0, 2, 2},
+		{0, 10, 3},
+		{1, 0, 4},
+		// escape sequences are skipped
+		{1, 3, 11},
+		{1, 5, 13},
+		{2, 1, 20},
+		{-1, 0, 0},
+		{5, 0, 22},
+	}
+
+	for i, tc := range tt {
+		offset := VisualToSource(src, lines, tc.Row, tc.Col)
+		if offset != tc.Offset {
+			t.Errorf("Test %d, expected:\n\n`%d`\n\nActual Output:\n\n`%d`", i, tc.Offset, offset)
+		}
+	}
+}
+
+func TestAnchors(t *testing.T) {
+	// "a\tb" with the tab expanded to the tab stop at column 4, put on a
+	// line starting with a marker of 2 cells
+	src := []byte("a\tb")
+	lines := []Line{
+		{SourceStart: 0, SourceEnd: 3, OutputStart: 0, OutputEnd: 7, Width: 7, Anchors: []Anchor{
+			{Source: 0, Col: 2},
+			{Source: 2, Col: 6},
+		}},
+	}
+
+	for i, tc := range []struct {
+		Offset int
+		Col    int
+	}{
+		{0, 2},
+		{1, 3},
+		{2, 6},
+		{3, 7},
+	} {
+		if row, col := SourceToVisual(src, lines, tc.Offset); row != 0 || col != tc.Col {
+			t.Errorf("Test %d, expected:\n\n`0:%d`\n\nActual Output:\n\n`%d:%d`", i, tc.Col, row, col)
+		}
+	}
+
+	for i, tc := range []struct {
+		Col    int
+		Offset int
+	}{
+		// the marker
+		{0, 0},
+		{2, 0},
+		// the cells of the tab
+		{3, 1},
+		{5, 1},
+		{6, 2},
+		{9, 3},
+	} {
+		if offset := VisualToSource(src, lines, 0, tc.Col); offset != tc.Offset {
+			t.Errorf("Test %d, expected:\n\n`%d`\n\nActual Output:\n\n`%d`", i, tc.Offset, offset)
+		}
+	}
+}
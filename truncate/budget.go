@@ -0,0 +1,67 @@
+package truncate
+
+import (
+	"sort"
+
+	"github.com/muesli/reflow/ansi"
+)
+
+// writeBudget truncates b at the width, or at the widest cell width below it
+// whose result still fits the byte and UTF-16 budgets. Without a width, b is
+// kept as a whole if it fits.
+func (w *Writer) writeBudget(b []byte) (int, error) {
+	max := w.Width
+	if max == 0 {
+		max = uint(ansi.PrintableRuneWidth(string(b)) + ansi.PrintableRuneWidth(w.Tail))
+	}
+
+	truncate := func(width uint) *Writer {
+		f := w.clone(width)
+		f.Bidi = w.Bidi
+		_, _ = f.Write(b)
+		return f
+	}
+
+	f := truncate(max)
+	if !w.fits(f.Bytes()) {
+		// the result of a cut grows with the width, unlike the content
+		// left alone, so the widest fitting cut can be searched for
+		n := sort.Search(int(max), func(i int) bool {
+			return !w.fits(truncate(uint(i)).Bytes())
+		})
+		if n == 0 {
+			// not even the tail fits
+			w.truncated = true
+			return len(b), nil
+		}
+		f = truncate(uint(n - 1))
+	}
+
+	if f.Truncated() {
+		w.truncated = true
+	}
+	if _, err := w.ansiWriter.Write(f.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// fits reports whether b stays within the byte and UTF-16 budgets.
+func (w *Writer) fits(b []byte) bool {
+	if w.MaxBytes > 0 && len(b) > w.MaxBytes {
+		return false
+	}
+	return w.MaxUTF16 <= 0 || utf16Len(string(b)) <= w.MaxUTF16
+}
+
+// utf16Len returns the number of UTF-16 code units needed to encode s.
+func utf16Len(s string) int {
+	var n int
+	for _, c := range s {
+		n++
+		if c >= 0x10000 {
+			n++
+		}
+	}
+	return n
+}
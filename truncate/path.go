@@ -0,0 +1,154 @@
+package truncate
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/muesli/reflow/ansi"
+)
+
+// ellipsis replaces collapsed directories.
+const ellipsis = "…"
+
+// Path shortens a file path or URL to the given printable cell width. Middle
+// directories are collapsed to an ellipsis first, then directories get
+// abbreviated to their first letter. The root and the file name are kept as
+// long as possible. ANSI sequences are kept intact.
+func Path(s string, width uint) string {
+	if uint(ansi.PrintableRuneWidth(s)) <= width {
+		return s
+	}
+
+	var scheme string
+	if i := strings.Index(s, "://"); i > 0 {
+		scheme, s = s[:i+3], s[i+3:]
+	}
+
+	sep := "/"
+	if !strings.Contains(s, sep) && strings.Contains(s, `\`) {
+		sep = `\`
+	}
+
+	parts := strings.Split(s, sep)
+	var root []string
+	if len(parts) > 1 && (scheme != "" || isRoot(parts[0])) {
+		root, parts = parts[:1], parts[1:]
+	}
+	if len(parts) < 2 {
+		return pathTail(scheme+s, width)
+	}
+	dirs, file := parts[:len(parts)-1], parts[len(parts)-1]
+
+	join := func(dirs []string) string {
+		p := append(append(append([]string{}, root...), dirs...), file)
+		return scheme + strings.Join(p, sep)
+	}
+	fits := func(p string) bool {
+		return uint(ansi.PrintableRuneWidth(p)) <= width
+	}
+
+	// collapse middle directories, keeping the first one
+	for n := len(dirs) - 1; n >= 2; n-- {
+		if p := join(collapse(dirs, n)); fits(p) {
+			return p
+		}
+	}
+
+	// abbreviate directories
+	short := make([]string, len(dirs))
+	for i, d := range dirs {
+		short[i] = abbreviate(d)
+	}
+	for n := len(short); n >= 2; n-- {
+		if p := join(collapse(short, n)); fits(p) {
+			return p
+		}
+	}
+
+	p := join(collapse(dirs, 0))
+	if fits(p) {
+		return p
+	}
+	return pathTail(p, width)
+}
+
+// isRoot reports whether the first element of a path is its root, like an
+// empty string for absolute paths, a drive letter or the home directory.
+func isRoot(s string) bool {
+	return s == "" || s == "~" || strings.HasSuffix(s, ":")
+}
+
+// collapse keeps the first and the last n-1 directories, replacing the ones
+// in between with an ellipsis. Escape sequences of the replaced directories
+// are kept, so styles stay intact.
+func collapse(dirs []string, n int) []string {
+	if n >= len(dirs) {
+		return dirs
+	}
+
+	var keep []string
+	var from int
+	if n > 0 {
+		keep = append(keep, dirs[0])
+		from = 1
+	}
+	to := len(dirs) - n + from
+
+	var seqs strings.Builder
+	for _, d := range dirs[from:to] {
+		_, _ = seqs.WriteString(sequences(d))
+	}
+	keep = append(keep, ellipsis+seqs.String())
+	return append(keep, dirs[to:]...)
+}
+
+// abbreviate shortens a directory to its first letter, keeping the dot of
+// hidden directories and all escape sequences.
+func abbreviate(s string) string {
+	var b strings.Builder
+	var inSeq bool
+	var n int
+	keep := 1
+
+	for _, c := range s {
+		if c == ansi.Marker {
+			inSeq = true
+		}
+		if inSeq {
+			if c != ansi.Marker && ansi.IsTerminator(c) {
+				inSeq = false
+			}
+			_, _ = b.WriteRune(c)
+			continue
+		}
+
+		if n == 0 && c == '.' && utf8.RuneCountInString(s) > 1 {
+			keep = 2
+		}
+		if n < keep {
+			_, _ = b.WriteRune(c)
+		}
+		n++
+	}
+
+	return b.String()
+}
+
+// sequences returns all escape sequences in s.
+func sequences(s string) string {
+	var b strings.Builder
+	for _, t := range tokenize(s) {
+		if t.seq {
+			_, _ = b.WriteString(t.s)
+		}
+	}
+	return b.String()
+}
+
+// pathTail keeps the end of s, which holds the file name.
+func pathTail(s string, width uint) string {
+	f := NewWriter(width, ellipsis)
+	f.Position = Left
+	_, _ = f.Write([]byte(s))
+	return f.String()
+}
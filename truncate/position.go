@@ -0,0 +1,251 @@
+package truncate
+
+import (
+	"bytes"
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+
+	"github.com/muesli/reflow/ansi"
+)
+
+// Position describes where content gets cut off.
+type Position int
+
+// Available positions.
+const (
+	// Right keeps the start of the content and puts the tail at its end.
+	Right Position = iota
+	// Left keeps the end of the content and puts the tail at its start.
+	Left
+	// Middle keeps both ends of the content and puts the tail in between.
+	Middle
+)
+
+// token is either a printable rune or a complete escape sequence.
+type token struct {
+	s     string
+	width uint
+	seq   bool
+}
+
+// tokenize splits s into printable runes and escape sequences.
+func tokenize(s string) []token {
+	var tokens []token
+	var seq bytes.Buffer
+	var inSeq bool
+
+	for _, c := range s {
+		if c == ansi.Marker {
+			inSeq = true
+			_, _ = seq.WriteRune(c)
+		} else if inSeq {
+			_, _ = seq.WriteRune(c)
+			if ansi.IsTerminator(c) {
+				inSeq = false
+				tokens = append(tokens, token{s: seq.String(), seq: true})
+				seq.Reset()
+			}
+		} else {
+			tokens = append(tokens, token{s: string(c), width: uint(runewidth.RuneWidth(c))})
+		}
+	}
+	if seq.Len() > 0 {
+		tokens = append(tokens, token{s: seq.String(), seq: true})
+	}
+
+	return tokens
+}
+
+// writePosition truncates b as a whole, or line by line in PerLine mode, at
+// the configured position.
+func (w *Writer) writePosition(b []byte) (int, error) {
+	if !w.PerLine {
+		if err := w.cutAt(tokenize(string(b))); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	for i, l := range bytes.Split(b, []byte{'\n'}) {
+		if i > 0 {
+			if _, err := w.ansiWriter.Write([]byte{'\n'}); err != nil {
+				return 0, err
+			}
+		}
+		if err := w.cutAt(tokenize(string(l))); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// cutAt writes tokens, dropping content at the configured position if they
+// don't fit the width. The style active at the cut is reset before the tail
+// and restored after it.
+func (w *Writer) cutAt(tokens []token) error {
+	var total uint
+	for _, t := range tokens {
+		total += t.width
+	}
+	// on the right, content only fits if it leaves room for the tail, just
+	// like when it gets truncated as it streams in, see Write
+	tw := uint(ansi.PrintableRuneWidth(w.Tail))
+	fits := total <= w.Width
+	if w.Position == Right {
+		fits = w.Width >= tw && total <= w.Width-tw
+	}
+	if fits {
+		return w.writeTokens(tokens)
+	}
+
+	w.truncated = true
+	if w.Width < tw {
+		_, err := io.WriteString(w.ansiWriter.Forward, w.Tail)
+		return err
+	}
+	limit := w.Width - tw
+
+	var head uint
+	switch w.Position {
+	case Right:
+		head = limit
+	case Middle:
+		head = (limit + 1) / 2
+	}
+
+	// keep the start of the content
+	var i int
+	var width uint
+	for ; w.Position != Left && i < len(tokens); i++ {
+		if width+tokens[i].width > head {
+			break
+		}
+		width += tokens[i].width
+	}
+	var straddle *token
+	if k := w.boundary(tokens, i); k != i {
+		i = k
+	} else if i < len(tokens) && width < head {
+		straddle = &tokens[i]
+	}
+	if w.TrimPunctuation && straddle == nil {
+		for i > 0 && !tokens[i-1].seq && isTrimmable(tokens[i-1].s) {
+			i--
+		}
+	}
+	if err := w.writeTokens(tokens[:i]); err != nil {
+		return err
+	}
+	if straddle != nil {
+		if err := w.writeWide(*straddle); err != nil {
+			return err
+		}
+	}
+
+	if w.Position == Right {
+		if _, err := io.WriteString(w.ansiWriter.Forward, w.Tail); err != nil {
+			return err
+		}
+		if w.ansiWriter.LastSequence() != "" {
+			w.ansiWriter.ResetAnsi()
+		}
+		return nil
+	}
+	if w.ansiWriter.LastSequence() != "" {
+		w.ansiWriter.ResetAnsi()
+	}
+
+	// keep the end of the content
+	j := len(tokens)
+	width = 0
+	for ; j > i; j-- {
+		if width+tokens[j-1].width > limit-head {
+			break
+		}
+		width += tokens[j-1].width
+	}
+
+	for _, t := range tokens[i:j] {
+		if t.seq {
+			for _, c := range t.s {
+				w.track(c)
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w.ansiWriter.Forward, w.Tail); err != nil {
+		return err
+	}
+	w.ansiWriter.RestoreAnsi()
+	if j > i && width < limit-head {
+		if err := w.writeWide(tokens[j-1]); err != nil {
+			return err
+		}
+	}
+
+	return w.writeTokens(tokens[j:])
+}
+
+// boundary returns the start of the whitespace before the last word of
+// tokens[:i] if WordBoundary is set and the cut at i splits a word. Otherwise
+// i is returned, which results in a hard cut.
+func (w *Writer) boundary(tokens []token, i int) int {
+	if !w.WordBoundary || i >= len(tokens) {
+		return i
+	}
+
+	k := i
+	for k > 0 && (tokens[k].seq || !isSpace(tokens[k].s)) {
+		k--
+	}
+	if k == 0 {
+		return i
+	}
+	for k > 0 && (tokens[k-1].seq || isSpace(tokens[k-1].s)) {
+		k--
+	}
+	if k == 0 {
+		return i
+	}
+	return k
+}
+
+func isSpace(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r)
+}
+
+func isTrimmable(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsSpace(r) || unicode.IsPunct(r)
+}
+
+// writeWide handles a double-width rune straddling the cut according to the
+// WidePolicy.
+func (w *Writer) writeWide(t token) error {
+	if t.width < 2 {
+		return nil
+	}
+
+	var err error
+	switch w.WidePolicy {
+	case WidePad:
+		_, err = w.ansiWriter.Write([]byte(" "))
+	case WideOverflow:
+		_, err = w.ansiWriter.Write([]byte(t.s))
+	}
+	return err
+}
+
+// writeTokens writes tokens unchanged.
+func (w *Writer) writeTokens(tokens []token) error {
+	for _, t := range tokens {
+		if _, err := w.ansiWriter.Write([]byte(t.s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
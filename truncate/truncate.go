@@ -3,25 +3,91 @@ package truncate
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 
 	"github.com/mattn/go-runewidth"
 
 	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/bidi"
+)
+
+// WidePolicy describes how double-width runes are handled if only a single
+// cell is left for them.
+type WidePolicy int
+
+// Available policies.
+const (
+	// WideDrop leaves the last cell empty, so the result is a cell short.
+	WideDrop WidePolicy = iota
+	// WidePad fills the last cell with a space, so the result has the
+	// exact width.
+	WidePad
+	// WideOverflow keeps the rune, so the result exceeds the width by a
+	// cell.
+	WideOverflow
 )
 
 type Writer struct {
-	width uint
-	tail  string
+	// Width is the printable cell width content gets truncated at.
+	Width uint
+	// Tail is added wherever content got cut off. It counts towards the
+	// width.
+	Tail string
+
+	// Bidi reorders the truncated result from logical to visual order, so
+	// right-to-left scripts like Hebrew or Arabic display correctly. Content
+	// is still cut at its logical end.
+	Bidi bool
+
+	// WidePolicy decides about double-width runes straddling the cut.
+	WidePolicy WidePolicy
+
+	// Position decides where content gets cut off. Left and Middle, just
+	// like Bidi, WordBoundary and TrimPunctuation, need to see the whole
+	// content, so it's held back until the result is asked for: Bytes and
+	// String truncate all content written so far, while writers created by
+	// NewWriterPipe write the result on Close.
+	Position Position
+
+	// WordBoundary cuts at the last word boundary that fits, instead of in
+	// the middle of a word. Words too long to fit on their own still get
+	// cut.
+	WordBoundary bool
+
+	// TrimPunctuation removes trailing whitespace and punctuation before the
+	// tail is added.
+	TrimPunctuation bool
+
+	// MaxBytes caps the result at a number of bytes, MaxUTF16 at a number
+	// of UTF-16 code units, as some sinks limit those rather than cells.
+	// The tail and any reset sequence count towards the budgets, and runes
+	// or escape sequences never get split. If Width is 0, only the budgets
+	// apply. Like Position, budgets need to see the whole content.
+	MaxBytes int
+	MaxUTF16 int
+
+	// PerLine truncates every line on its own, each with its own tail.
+	// Styles are reset at each cut and restored on the next line.
+	PerLine bool
 
 	ansiWriter *ansi.Writer
 	buf        bytes.Buffer
 	ansi       bool
+
+	// content held back until it's complete, see Position
+	input    bytes.Buffer
+	rendered bool
+
+	curWidth  uint
+	cut       bool
+	restore   bool
+	truncated bool
 }
 
 func NewWriter(width uint, tail string) *Writer {
 	w := &Writer{
-		width: width,
-		tail:  tail,
+		Width: width,
+		Tail:  tail,
 	}
 	w.ansiWriter = &ansi.Writer{
 		Forward: &w.buf,
@@ -31,8 +97,8 @@ func NewWriter(width uint, tail string) *Writer {
 
 func NewWriterPipe(forward io.Writer, width uint, tail string) *Writer {
 	return &Writer{
-		width: width,
-		tail:  tail,
+		Width: width,
+		Tail:  tail,
 		ansiWriter: &ansi.Writer{
 			Forward: forward,
 		},
@@ -71,15 +137,37 @@ func StringWithTail(s string, width uint, tail string) string {
 // Write truncates content at the given printable cell width, leaving any
 // ansi sequences intact.
 func (w *Writer) Write(b []byte) (int, error) {
-	tw := ansi.PrintableRuneWidth(w.tail)
-	if w.width < uint(tw) {
-		return w.buf.WriteString(w.tail)
+	if w.ansiWriter == nil {
+		w.ansiWriter = &ansi.Writer{
+			Forward: &w.buf,
+		}
+	}
+
+	if w.held() {
+		_, _ = w.input.Write(b)
+		w.rendered = false
+		return len(b), nil
+	}
+	if w.PerLine {
+		return w.writeLines(b)
 	}
 
-	w.width -= uint(tw)
-	var curWidth uint
+	if w.truncated {
+		return len(b), nil
+	}
+
+	tw := uint(ansi.PrintableRuneWidth(w.Tail))
+	if w.Width < tw {
+		w.truncated = true
+		if _, err := io.WriteString(w.ansiWriter.Forward, w.Tail); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	limit := w.Width - tw
 
 	for _, c := range string(b) {
+		var rw uint
 		if c == ansi.Marker {
 			// ANSI escape sequence
 			w.ansi = true
@@ -89,16 +177,29 @@ func (w *Writer) Write(b []byte) (int, error) {
 				w.ansi = false
 			}
 		} else {
-			curWidth += uint(runewidth.RuneWidth(c))
+			rw = uint(runewidth.RuneWidth(c))
 		}
 
-		if curWidth > w.width {
-			n, err := w.buf.WriteString(w.tail)
+		if w.curWidth+rw > limit {
+			if rw > 1 && w.curWidth < limit {
+				switch w.WidePolicy {
+				case WidePad:
+					_, _ = w.ansiWriter.Write([]byte(" "))
+				case WideOverflow:
+					_, _ = w.ansiWriter.Write([]byte(string(c)))
+				}
+			}
+
+			w.truncated = true
+			if _, err := io.WriteString(w.ansiWriter.Forward, w.Tail); err != nil {
+				return 0, err
+			}
 			if w.ansiWriter.LastSequence() != "" {
 				w.ansiWriter.ResetAnsi()
 			}
-			return n, err
+			return len(b), nil
 		}
+		w.curWidth += rw
 
 		_, err := w.ansiWriter.Write([]byte(string(c)))
 		if err != nil {
@@ -109,12 +210,212 @@ func (w *Writer) Write(b []byte) (int, error) {
 	return len(b), nil
 }
 
+// held reports whether content is held back until it's complete, as it needs
+// to be truncated as a whole, see Position.
+func (w *Writer) held() bool {
+	return w.MaxBytes > 0 || w.MaxUTF16 > 0 || w.Bidi ||
+		w.Position != Right || w.WordBoundary || w.TrimPunctuation
+}
+
+// render truncates all content held back, unless that happened already.
+// Writers created by NewWriterPipe can't take back their output, so their
+// content is truncated only once, see Close.
+func (w *Writer) render() error {
+	if w.rendered || w.ansiWriter == nil || !w.held() {
+		return nil
+	}
+	w.rendered = true
+
+	pipe := w.ansiWriter.Forward != &w.buf
+	if !pipe {
+		w.buf.Reset()
+		*w.ansiWriter = ansi.Writer{
+			Forward: &w.buf,
+		}
+		w.truncated = false
+	}
+
+	b := w.input.Bytes()
+	if pipe {
+		defer w.input.Reset()
+	}
+
+	var err error
+	switch {
+	case w.MaxBytes > 0 || w.MaxUTF16 > 0:
+		_, err = w.writeBudget(b)
+	case w.Bidi:
+		_, err = w.writeBidi(b)
+	default:
+		_, err = w.writePosition(b)
+	}
+	return err
+}
+
+// writeLines truncates every line of b on its own. Escape sequences found in
+// the dropped part of a line still count towards the style that gets restored
+// on the next line.
+func (w *Writer) writeLines(b []byte) (int, error) {
+	var limit uint
+	if tw := uint(ansi.PrintableRuneWidth(w.Tail)); w.Width > tw {
+		limit = w.Width - tw
+	}
+
+	for _, c := range string(b) {
+		if c == '\n' && !w.ansi {
+			if w.cut {
+				w.cut = false
+				w.restore = true
+			}
+			w.curWidth = 0
+			if _, err := w.ansiWriter.Write([]byte{'\n'}); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if w.cut {
+			if c == '\r' {
+				if _, err := w.ansiWriter.Forward.Write([]byte{'\r'}); err != nil {
+					return 0, err
+				}
+			} else if c == ansi.Marker || w.ansi {
+				w.track(c)
+			}
+			continue
+		}
+
+		if w.restore {
+			w.restore = false
+			w.ansiWriter.RestoreAnsi()
+		}
+
+		if c == ansi.Marker {
+			w.ansi = true
+		} else if w.ansi {
+			if ansi.IsTerminator(c) {
+				w.ansi = false
+			}
+		} else {
+			rw := uint(runewidth.RuneWidth(c))
+			if w.curWidth+rw > limit {
+				if rw > 1 && w.curWidth < limit {
+					switch w.WidePolicy {
+					case WidePad:
+						_, _ = w.ansiWriter.Write([]byte(" "))
+					case WideOverflow:
+						_, _ = w.ansiWriter.Write([]byte(string(c)))
+					}
+				}
+
+				if _, err := io.WriteString(w.ansiWriter.Forward, w.Tail); err != nil {
+					return 0, err
+				}
+				if w.ansiWriter.LastSequence() != "" {
+					w.ansiWriter.ResetAnsi()
+				}
+				w.cut = true
+				w.truncated = true
+				continue
+			}
+			w.curWidth += rw
+		}
+
+		if _, err := w.ansiWriter.Write([]byte(string(c))); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// track feeds a rune of a dropped escape sequence to the ansi writer without
+// printing it, so the style state stays current.
+func (w *Writer) track(c rune) {
+	if c == ansi.Marker {
+		w.ansi = true
+	} else if ansi.IsTerminator(c) {
+		w.ansi = false
+	}
+
+	forward := w.ansiWriter.Forward
+	w.ansiWriter.Forward = ioutil.Discard
+	_, _ = w.ansiWriter.Write([]byte(string(c)))
+	w.ansiWriter.Forward = forward
+}
+
+// clone returns a Writer for the given width with the configuration of w,
+// leaving out Bidi and the budgets.
+func (w *Writer) clone(width uint) *Writer {
+	f := NewWriter(width, w.Tail)
+	f.WidePolicy = w.WidePolicy
+	f.Position = w.Position
+	f.PerLine = w.PerLine
+	f.WordBoundary = w.WordBoundary
+	f.TrimPunctuation = w.TrimPunctuation
+	return f
+}
+
+// writeBidi truncates b in logical order, then reorders the result for
+// display.
+func (w *Writer) writeBidi(b []byte) (int, error) {
+	f := w.clone(w.Width)
+	if _, err := f.Write(b); err != nil {
+		return 0, err
+	}
+	if f.Truncated() {
+		w.truncated = true
+	}
+
+	if _, err := w.ansiWriter.Write(bidi.Bytes(f.Bytes())); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Truncated reports whether any content got cut off since the Writer was
+// created or last reset. Writers created by NewWriterPipe only know about
+// content held back once they got closed, see Close.
+func (w *Writer) Truncated() bool {
+	if w.ansiWriter == nil || w.ansiWriter.Forward == &w.buf {
+		_ = w.render()
+	}
+	return w.truncated
+}
+
+// Close finishes the truncation. Writers created by NewWriterPipe write any
+// content held back on Close, see Position.
+func (w *Writer) Close() error {
+	return w.render()
+}
+
+// Reset discards the result and all state, so the Writer can be reused with
+// its current configuration.
+func (w *Writer) Reset() {
+	w.buf.Reset()
+	if w.ansiWriter != nil {
+		*w.ansiWriter = ansi.Writer{
+			Forward: w.ansiWriter.Forward,
+		}
+	}
+
+	w.input.Reset()
+	w.rendered = false
+	w.ansi = false
+	w.curWidth = 0
+	w.cut = false
+	w.restore = false
+	w.truncated = false
+}
+
 // Bytes returns the truncated result as a byte slice.
 func (w *Writer) Bytes() []byte {
+	_ = w.render()
 	return w.buf.Bytes()
 }
 
 // String returns the truncated result as a string.
 func (w *Writer) String() string {
+	_ = w.render()
 	return w.buf.String()
 }
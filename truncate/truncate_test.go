@@ -3,6 +3,7 @@ package truncate
 import (
 	"bytes"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/muesli/reflow/ansi"
@@ -117,6 +118,524 @@ func TestTruncate(t *testing.T) {
 	}
 }
 
+func TestTruncateBidi(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		width    uint
+		tail     string
+		in       string
+		expected string
+	}{
+		// Left-to-right text is unaffected:
+		{
+			4,
+			"…",
+			"foobar",
+			"foo…",
+		},
+		// Right-to-left text is cut at its logical end:
+		{
+			4,
+			"…",
+			"אבגדהו",
+			"…גבא",
+		},
+		// ANSI sequences stay attached to their runes:
+		{
+			2,
+			"",
+			"\x1B[7mאב\x1B[0mג",
+			"\x1B[7mבא\x1B[0m",
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.width, tc.tail)
+		f.Bidi = true
+
+		_, err := f.Write([]byte(tc.in))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.expected, f.String())
+		}
+	}
+}
+
+func TestTruncateWidePolicy(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		policy   WidePolicy
+		tail     string
+		in       string
+		expected string
+	}{
+		{
+			WideDrop,
+			"…",
+			"a你好世界",
+			"a你…",
+		},
+		{
+			WidePad,
+			"…",
+			"a你好世界",
+			"a你 …",
+		},
+		{
+			WideOverflow,
+			"…",
+			"a你好世界",
+			"a你好…",
+		},
+		// Styles are reset after the padding:
+		{
+			WidePad,
+			"",
+			"\x1B[1mab你好\x1B[0m",
+			"\x1B[1mab你 \x1B[0m",
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(5, tc.tail)
+		f.WidePolicy = tc.policy
+
+		_, err := f.Write([]byte(tc.in))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.expected, f.String())
+		}
+	}
+}
+
+func TestTruncatePerLine(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		tail     string
+		in       string
+		expected string
+	}{
+		// Every line gets truncated on its own:
+		{
+			"…",
+			"foobar\nfoo\nbarbaz",
+			"foo…\nfoo\nbar…",
+		},
+		// Line endings are kept:
+		{
+			"",
+			"foobar\r\nfoo\r\n",
+			"foob\r\nfoo\r\n",
+		},
+		// Styles are reset at each cut and restored on the next line:
+		{
+			"…",
+			"\x1B[1mfoobar\nfoobar\x1B[0m",
+			"\x1B[1mfoo…\x1B[0m\n\x1B[1mfoo…\x1B[0m",
+		},
+		// Sequences in the dropped part of a line still apply:
+		{
+			"",
+			"\x1B[1mfoobar\x1B[0m\nfoo",
+			"\x1B[1mfoob\x1B[0m\nfoo",
+		},
+		// Style is not restored on trailing newlines:
+		{
+			"",
+			"\x1B[1mfoobar\n",
+			"\x1B[1mfoob\x1B[0m\n",
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(4, tc.tail)
+		f.PerLine = true
+
+		_, err := f.Write([]byte(tc.in))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.expected, f.String())
+		}
+	}
+}
+
+func TestTruncatePosition(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		position Position
+		width    uint
+		in       string
+		expected string
+	}{
+		// Content that fits is left alone:
+		{
+			Left,
+			7,
+			"a3f49c2",
+			"a3f49c2",
+		},
+		{
+			Left,
+			7,
+			"feature/login-fix",
+			"…in-fix",
+		},
+		{
+			Middle,
+			7,
+			"a3f49c2e",
+			"a3f…c2e",
+		},
+		{
+			Middle,
+			6,
+			"a3f49c2e",
+			"a3f…2e",
+		},
+		// Styles are correct on both sides of the tail:
+		{
+			Middle,
+			5,
+			"\x1B[1mfoo\x1B[0mbar\x1B[7mbaz\x1B[0m",
+			"\x1B[1mfo\x1B[0m…\x1B[7maz\x1B[0m",
+		},
+		{
+			Left,
+			4,
+			"\x1B[1mfoobar\x1B[0m",
+			"…\x1B[1mbar\x1B[0m",
+		},
+		// Double-width runes straddling the cut:
+		{
+			Left,
+			4,
+			"你好世界",
+			"…界",
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.width, "…")
+		f.Position = tc.position
+
+		_, err := f.Write([]byte(tc.in))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.expected, f.String())
+		}
+	}
+}
+
+func TestTruncateWordBoundary(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		width    uint
+		trim     bool
+		in       string
+		expected string
+	}{
+		// Cut at the last word boundary that fits:
+		{
+			14,
+			false,
+			"The quick brown fox",
+			"The quick…",
+		},
+		// Word ending right at the cut:
+		{
+			10,
+			false,
+			"The quick brown fox",
+			"The quick…",
+		},
+		// Punctuation is kept by default:
+		{
+			10,
+			false,
+			"Hello, big world",
+			"Hello,…",
+		},
+		{
+			10,
+			true,
+			"Hello, big world",
+			"Hello…",
+		},
+		// Hard cut if the first word is too long:
+		{
+			5,
+			false,
+			"Supercalifragilistic word",
+			"Supe…",
+		},
+		// Content fits only if it leaves room for the tail, as without
+		// WordBoundary:
+		{
+			6,
+			false,
+			"foobar",
+			"fooba…",
+		},
+		{
+			6,
+			true,
+			"fooba",
+			"fooba",
+		},
+		// Escape sequences are preserved:
+		{
+			14,
+			false,
+			"\x1B[1mThe quick\x1B[0m brown fox",
+			"\x1B[1mThe quick…\x1B[0m",
+		},
+		{
+			14,
+			false,
+			"\x1B[1mThe quick brown fox\x1B[0m",
+			"\x1B[1mThe quick…\x1B[0m",
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.width, "…")
+		f.WordBoundary = true
+		f.TrimPunctuation = tc.trim
+
+		_, err := f.Write([]byte(tc.in))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.expected, f.String())
+		}
+	}
+}
+
+func TestTruncatePath(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		width    uint
+		in       string
+		expected string
+	}{
+		// Paths that fit are left alone:
+		{
+			30,
+			"/home/user/project/file.go",
+			"/home/user/project/file.go",
+		},
+		// Middle directories are collapsed:
+		{
+			24,
+			"/home/user/project/file.go",
+			"/home/…/project/file.go",
+		},
+		// Directories are abbreviated:
+		{
+			14,
+			"/home/user/project/file.go",
+			"/h/u/p/file.go",
+		},
+		// Hidden directories keep their dot:
+		{
+			17,
+			"~/.config/reflow/config.yml",
+			"~/.c/r/config.yml",
+		},
+		// Root and file name are kept as long as possible:
+		{
+			10,
+			"/home/user/project/file.go",
+			"/…/file.go",
+		},
+		{
+			5,
+			"/home/user/project/file.go",
+			"…e.go",
+		},
+		// Backslash separators:
+		{
+			18,
+			`C:\Users\me\Documents\file.txt`,
+			`C:\U\m\D\file.txt`,
+		},
+		// URLs keep their scheme and host:
+		{
+			40,
+			"https://example.com/docs/api/v2/index.html",
+			"https://example.com/docs/…/v2/index.html",
+		},
+		{
+			36,
+			"https://example.com/docs/api/v2/index.html",
+			"https://example.com/d/a/v/index.html",
+		},
+		// Colored paths stay intact:
+		{
+			14,
+			"\x1B[34m/home/user/project/\x1B[0mfile.go",
+			"\x1B[34m/h/u/p/\x1B[0mfile.go",
+		},
+	}
+
+	for i, tc := range tt {
+		actual := Path(tc.in, tc.width)
+		if actual != tc.expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.expected, actual)
+		}
+	}
+}
+
+func TestTruncateBudget(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		width     uint
+		maxBytes  int
+		maxUTF16  int
+		in        string
+		expected  string
+		truncated bool
+	}{
+		// Content within the budget is left alone:
+		{
+			0,
+			10,
+			0,
+			"foobar",
+			"foobar",
+			false,
+		},
+		// The tail counts towards the budget:
+		{
+			0,
+			6,
+			0,
+			"foobar!",
+			"foo…",
+			true,
+		},
+		// Runes are never split:
+		{
+			0,
+			10,
+			0,
+			"äöüäöü",
+			"äöü…",
+			true,
+		},
+		// The reset sequence counts towards the budget:
+		{
+			0,
+			15,
+			0,
+			"\x1B[1mfoobarbazqux",
+			"\x1B[1mfoob…\x1B[0m",
+			true,
+		},
+		// Escape sequences are never split:
+		{
+			0,
+			6,
+			0,
+			"\x1B[1mfoobar",
+			"…",
+			true,
+		},
+		// UTF-16 code units:
+		{
+			0,
+			0,
+			4,
+			"😀😀😀",
+			"😀…",
+			true,
+		},
+		// Budgets apply in addition to the width:
+		{
+			5,
+			100,
+			0,
+			"foobar",
+			"foob…",
+			true,
+		},
+		{
+			5,
+			5,
+			0,
+			"foobar",
+			"fo…",
+			true,
+		},
+		// Content fitting the budgets as a whole is kept, even if cutting it
+		// off would take up more room:
+		{
+			0,
+			160,
+			0,
+			strings.Repeat("a", 159),
+			strings.Repeat("a", 159),
+			false,
+		},
+		{
+			0,
+			4,
+			0,
+			"abcd",
+			"abcd",
+			false,
+		},
+		// Nothing fits:
+		{
+			0,
+			2,
+			0,
+			"foobar",
+			"",
+			true,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.width, "…")
+		f.MaxBytes = tc.maxBytes
+		f.MaxUTF16 = tc.maxUTF16
+
+		_, err := f.Write([]byte(tc.in))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.expected, f.String())
+		}
+		if f.Truncated() != tc.truncated {
+			t.Errorf("Test %d, expected truncated to be %t", i, tc.truncated)
+		}
+	}
+}
+
 func TestTruncateString(t *testing.T) {
 	t.Parallel()
 
@@ -165,11 +684,144 @@ func TestNewWriterPipe(t *testing.T) {
 	}
 }
 
+func TestWriterReuse(t *testing.T) {
+	t.Parallel()
+
+	b := &bytes.Buffer{}
+	f := NewWriterPipe(b, 5, "…")
+
+	// The width stays the same across writes:
+	for _, s := range []string{"fo", "ob", "ar"} {
+		if _, err := f.Write([]byte(s)); err != nil {
+			t.Error(err)
+		}
+	}
+	if expected := "foob…"; b.String() != expected {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, b.String())
+	}
+	if !f.Truncated() {
+		t.Error("expected content to be truncated")
+	}
+
+	// Reset allows reusing the writer:
+	f = NewWriter(5, "…")
+	_, _ = f.Write([]byte("foobar"))
+	f.Reset()
+	f.Width = 3
+	f.Tail = "."
+	if _, err := f.Write([]byte("fo")); err != nil {
+		t.Error(err)
+	}
+	if expected := "fo"; f.String() != expected {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, f.String())
+	}
+	if f.Truncated() {
+		t.Error("expected content not to be truncated")
+	}
+
+	// A zero value writer is usable:
+	f = &Writer{Width: 2}
+	if _, err := f.Write([]byte("foo")); err != nil {
+		t.Error(err)
+	}
+	if expected := "fo"; f.String() != expected {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, f.String())
+	}
+}
+
+func TestTruncateMultipleWrites(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input    []string
+		Expected string
+		Setup    func(f *Writer)
+	}{
+		// Content is cut once, no matter how it got written:
+		{
+			[]string{"ab cd", " ef gh"},
+			"ab…",
+			func(f *Writer) { f.WordBoundary = true },
+		},
+		{
+			[]string{"ab cd.", " ef gh"},
+			"ab c…",
+			func(f *Writer) { f.TrimPunctuation = true },
+		},
+		{
+			[]string{"abcdefgh", "ijklmnop"},
+			"…mnop",
+			func(f *Writer) { f.Position = Left },
+		},
+		{
+			[]string{"abcdefgh", "ijklmnop"},
+			"ab…op",
+			func(f *Writer) { f.Position = Middle },
+		},
+		{
+			[]string{"abcdefgh", "ijklmnop"},
+			"abcd…",
+			func(f *Writer) { f.Bidi = true },
+		},
+		{
+			[]string{"abcdefgh", "ijklmnop"},
+			"abc…",
+			func(f *Writer) { f.MaxBytes = 6 },
+		},
+		// Content fitting as a whole isn't truncated:
+		{
+			[]string{"ab", "cd"},
+			"abcd",
+			func(f *Writer) { f.Position = Left },
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(5, "…")
+		tc.Setup(f)
+		for _, s := range tc.Input {
+			if _, err := f.Write([]byte(s)); err != nil {
+				t.Error(err)
+			}
+		}
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.Expected, f.String())
+		}
+	}
+
+	// Writers created by NewWriterPipe write the result on Close:
+	b := &bytes.Buffer{}
+	f := NewWriterPipe(b, 5, "…")
+	f.Position = Left
+	for _, s := range []string{"abcdefgh", "ijklmnop"} {
+		if _, err := f.Write([]byte(s)); err != nil {
+			t.Error(err)
+		}
+		// asking for the result before Close doesn't render it
+		if f.Truncated() {
+			t.Error("expected content not to be truncated before Close")
+		}
+	}
+	if b.Len() > 0 {
+		t.Errorf("expected no output before Close, got `%s`", b.String())
+	}
+	if err := f.Close(); err != nil {
+		t.Error(err)
+	}
+	if expected := "…mnop"; b.String() != expected {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, b.String())
+	}
+	if !f.Truncated() {
+		t.Error("expected content to be truncated")
+	}
+}
+
 func TestWriter_Error(t *testing.T) {
 	t.Parallel()
 
 	f := &Writer{
-		width:      2,
+		Width:      2,
 		ansiWriter: &ansi.Writer{Forward: fakeWriter{}},
 	}
 
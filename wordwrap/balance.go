@@ -0,0 +1,190 @@
+package wordwrap
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// segment is a piece of a paragraph, along with its source offset and the
+// indentation of its wrapped lines.
+type segment struct {
+	s      string
+	pos    int
+	indent string
+}
+
+// collect gathers the text of a paragraph, until its end allows to pick the
+// limits of its lines. Line breaks are wrapped right away.
+func (w *WordWrap) collect(s string) error {
+	if w.Limit == 0 && w.LimitFunc == nil {
+		return w.wrap(s)
+	}
+
+	for len(s) > 0 {
+		i := -1
+		if w.KeepNewlines || w.Refill {
+			i = strings.IndexFunc(s, func(c rune) bool {
+				return inGroup(w.Newline, c)
+			})
+		}
+		if i < 0 {
+			w.para = append(w.para, segment{s, w.srcPos, w.indent})
+			w.srcPos += len(s)
+			return nil
+		}
+
+		if i > 0 {
+			w.para = append(w.para, segment{s[:i], w.srcPos, w.indent})
+			w.srcPos += i
+		}
+		if err := w.wrapParagraph(); err != nil {
+			return err
+		}
+
+		_, size := utf8.DecodeRuneInString(s[i:])
+		if err := w.wrap(s[i : i+size]); err != nil {
+			return err
+		}
+		s = s[i+size:]
+	}
+	return nil
+}
+
+// wrapParagraph word-wraps the collected paragraph, after picking the limits
+// of its lines.
+func (w *WordWrap) wrapParagraph() error {
+	if len(w.para) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	for _, seg := range w.para {
+		_, _ = b.WriteString(seg.s)
+	}
+	text := b.String()
+	indentWidth := runewidth.StringWidth(w.para[len(w.para)-1].indent)
+
+	// the limits of the paragraph's lines, with every line capped at the
+	// given width
+	w.paraLine = w.line
+	capped := func(width int) func(int) int {
+		return func(line int) int {
+			l := w.lineLimit(w.paraLine + line)
+			if width < l {
+				return width
+			}
+			return l
+		}
+	}
+
+	// trial wraps the paragraph with the given limits, taking the content
+	// already on the current line and the indentation of wrapped lines
+	// into account
+	trial := func(limit func(int) int) []string {
+		return w.trial(text, func(line int) int {
+			if line == 0 {
+				return limit(line) - w.lineLen - w.spaceWidth
+			}
+			return limit(line) - indentWidth
+		})
+	}
+
+	lines := trial(capped(math.MaxInt32))
+	limit := capped(math.MaxInt32)
+	if w.Balance {
+		// narrow the paragraph until it would take another line
+		var width int
+		for i, l := range lines {
+			lw := w.cells(l)
+			if i == 0 {
+				lw += w.lineLen + w.spaceWidth
+			} else {
+				lw += indentWidth
+			}
+			if lw > width {
+				width = lw
+			}
+		}
+		for ; width > 1; width-- {
+			l := trial(capped(width - 1))
+			if len(l) != len(lines) {
+				break
+			}
+		}
+		limit = capped(width)
+		lines = trial(limit)
+	}
+
+	// pull words down onto the last line by narrowing the second to last
+	if n := len(lines); w.MinLastLineWords > 0 && n > 1 &&
+		len(strings.Fields(lines[n-1])) < w.MinLastLineWords {
+		width := w.cells(lines[n-2])
+		if n == 2 {
+			width += w.lineLen + w.spaceWidth
+		} else {
+			width += indentWidth
+		}
+
+		for ; width > 1; width-- {
+			l := trial(narrowed(limit, n-2, width-1))
+			if len(l) != n {
+				break
+			}
+			if len(strings.Fields(l[n-1])) >= w.MinLastLineWords {
+				limit = narrowed(limit, n-2, width-1)
+				break
+			}
+		}
+	}
+
+	// replay the paragraph with the picked limits
+	w.paraLimit = limit
+	pos, indent := w.srcPos, w.indent
+	for _, seg := range w.para {
+		w.srcPos = seg.pos
+		w.indent = seg.indent
+		if err := w.wrap(seg.s); err != nil {
+			return err
+		}
+	}
+	w.srcPos, w.indent = pos, indent
+	w.para = w.para[:0]
+	w.paraLimit = nil
+	return nil
+}
+
+// trial returns the lines text gets word-wrapped into with the given limits.
+func (w *WordWrap) trial(text string, limit func(int) int) []string {
+	f := NewWriter(0)
+	f.Breakpoints = w.Breakpoints
+	f.Newline = w.Newline
+	f.KeepNewlines = w.KeepNewlines
+	f.BreakFunc = w.BreakFunc
+	f.BreakCJK = w.BreakCJK
+	f.TabWidth = w.TabWidth
+	f.KeepTabs = w.KeepTabs
+	f.LimitFunc = func(line int) int {
+		// unlike LimitFunc, non-positive limits mean no room at all
+		if l := limit(line); l > 0 {
+			return l
+		}
+		return 1
+	}
+
+	_, _ = f.Write([]byte(text))
+	_ = f.Close()
+	return strings.Split(f.String(), "\n")
+}
+
+// narrowed returns limit, with the given line capped at width.
+func narrowed(limit func(int) int, line, width int) func(int) int {
+	return func(l int) int {
+		if l == line && width < limit(l) {
+			return width
+		}
+		return limit(l)
+	}
+}
@@ -0,0 +1,85 @@
+package wordwrap
+
+import (
+	"strings"
+
+	"github.com/muesli/reflow/bidi"
+)
+
+// paragraph holds the direction of a source paragraph, shared by all of its
+// wrapped lines.
+type paragraph struct {
+	dir bidi.Direction
+}
+
+// trackDirection determines the direction of the current paragraph from its
+// first strong rune, see Bidi.
+func (w *WordWrap) trackDirection(c rune) {
+	if !w.Bidi {
+		return
+	}
+	if w.paragraph == nil {
+		w.paragraph = &paragraph{}
+	}
+	if w.paragraph.dir == bidi.Auto {
+		w.paragraph.dir = bidi.RuneDirection(c)
+	}
+}
+
+// endLine assigns the current paragraph to the line just completed.
+func (w *WordWrap) endLine() {
+	if !w.Bidi {
+		return
+	}
+	if w.paragraph == nil {
+		w.paragraph = &paragraph{}
+	}
+	w.paragraphs = append(w.paragraphs, w.paragraph)
+}
+
+// endParagraph starts a new paragraph with the next line, as the source text
+// ended the current one.
+func (w *WordWrap) endParagraph() {
+	w.paragraph = nil
+}
+
+// reorder reorders the lines of s from logical to visual order, each in the
+// direction of the paragraph it belongs to. Lines not completed yet belong to
+// the current paragraph.
+func (w *WordWrap) reorder(s string) string {
+	var b strings.Builder
+	if w.bidiCR && strings.HasPrefix(s, "\n") {
+		// the LF of a CRLF line ending that got split up
+		_ = b.WriteByte('\n')
+		s = s[1:]
+	}
+	w.bidiCR = strings.HasSuffix(s, "\r")
+
+	for len(s) > 0 {
+		i := strings.IndexAny(s, "\r\n")
+		if i < 0 {
+			i = len(s)
+		}
+		j := i
+		if strings.HasPrefix(s[i:], "\r\n") {
+			j += 2
+		} else if i < len(s) {
+			j++
+		}
+
+		p := w.paragraph
+		if j > i && len(w.paragraphs) > 0 {
+			p, w.paragraphs = w.paragraphs[0], w.paragraphs[1:]
+		}
+		dir := bidi.Auto
+		if p != nil {
+			dir = p.dir
+		}
+
+		var line string
+		line, w.bidiStyle = bidi.ReorderDirection(s[:j], w.bidiStyle, dir)
+		_, _ = b.WriteString(line)
+		s = s[j:]
+	}
+	return b.String()
+}
@@ -0,0 +1,165 @@
+package wordwrap
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// Break describes whether a line may be broken between two runes.
+type Break int
+
+// Available breaks.
+const (
+	// BreakNone keeps both runes on the same line.
+	BreakNone Break = iota
+	// BreakBefore allows a line break before the next rune. The previous
+	// rune moves to the new line with the rest of its word, if the word
+	// exceeds the limit.
+	BreakBefore
+	// BreakAfter allows a line break after the previous rune, which stays
+	// on the current line even if it exceeds the limit, just like
+	// Breakpoints do.
+	BreakAfter
+	// BreakMandatory always breaks the line between both runes.
+	BreakMandatory
+)
+
+// BreakFunc returns whether a line may be broken between the runes prev and
+// next of a word.
+type BreakFunc func(prev, next rune) Break
+
+// BreakProse allows breaking lines after hyphens and dashes.
+func BreakProse(prev, next rune) Break {
+	if strings.ContainsRune("-‐–—", prev) && !strings.ContainsRune("-‐–—", next) {
+		return BreakAfter
+	}
+	return BreakNone
+}
+
+// BreakPaths allows breaking lines after the separators of paths, both '/'
+// and '\'.
+func BreakPaths(prev, next rune) Break {
+	if (prev == '/' || prev == '\\') && next != '/' && next != '\\' {
+		return BreakAfter
+	}
+	return BreakNone
+}
+
+// BreakDotted allows breaking lines before the dots of identifiers, e.g.
+// "foo.Bar().Baz".
+func BreakDotted(prev, next rune) Break {
+	if next == '.' && prev != '.' {
+		return BreakBefore
+	}
+	return BreakNone
+}
+
+// BreakCamelCase allows breaking lines between the parts of CamelCase
+// identifiers, e.g. "wordWrap".
+func BreakCamelCase(prev, next rune) Break {
+	if unicode.IsLower(prev) && unicode.IsUpper(next) {
+		return BreakBefore
+	}
+	return BreakNone
+}
+
+// AnyBreak combines several BreakFuncs, returning the first break other than
+// BreakNone.
+func AnyBreak(funcs ...BreakFunc) BreakFunc {
+	return func(prev, next rune) Break {
+		for _, f := range funcs {
+			if b := f(prev, next); b != BreakNone {
+				return b
+			}
+		}
+		return BreakNone
+	}
+}
+
+// breakAt returns whether the current word may be broken between prev and
+// next.
+func (w *WordWrap) breakAt(prev, next rune) Break {
+	if w.BreakFunc != nil {
+		if b := w.BreakFunc(prev, next); b != BreakNone {
+			return b
+		}
+	}
+	if w.BreakCJK && canBreakCJK(prev, next) {
+		return BreakBefore
+	}
+	return BreakNone
+}
+
+// addRune adds a rune other than whitespace or breakpoints to the current
+// word, breaking the line where needed.
+func (w *WordWrap) addRune(c rune, size int) {
+	if w.lastRune != 0 {
+		b := w.breakAt(w.lastRune, c)
+		if w.hang {
+			// the previous rune exceeded the limit, which it may only
+			// do if the line may be broken right after it
+			w.hang = false
+			if b != BreakAfter {
+				w.breakLine()
+			}
+		}
+
+		if b != BreakNone {
+			w.addWord()
+		}
+		if b == BreakMandatory {
+			w.addNewLine()
+		}
+		if w.full {
+			w.drop(c)
+			return
+		}
+	}
+
+	w.writeWord(c, w.pos, size)
+	w.lastRune = c
+
+	// add a line break if the current word would exceed the line's
+	// character limit
+	limit := w.limit()
+	if w.lineLen+w.spaceWidth+w.word.PrintableRuneWidth() > limit &&
+		w.word.PrintableRuneWidth() < limit && !w.indented() {
+		if w.BreakFunc != nil {
+			// wait for the next rune to tell whether this one may
+			// stay on the current line
+			w.hang = true
+			return
+		}
+		w.breakLine()
+	}
+}
+
+// indented reports whether the current line holds nothing but the continued
+// indentation of a refilled paragraph, which breaking wouldn't make room on.
+func (w *WordWrap) indented() bool {
+	return w.indent != "" && w.lineLen <= runewidth.StringWidth(w.indent)
+}
+
+// settle breaks the line before the current word if its last rune exceeded
+// the limit, as the word ended without a break after it.
+func (w *WordWrap) settle() {
+	if w.hang {
+		w.hang = false
+		w.breakLine()
+	}
+}
+
+// breakLine moves the current word to a new line.
+func (w *WordWrap) breakLine() {
+	if w.lastLine() {
+		w.clamp()
+		return
+	}
+
+	if w.Alignment == AlignJustify {
+		w.justify()
+	}
+	w.addNewLine()
+}
@@ -0,0 +1,36 @@
+package wordwrap
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// kinsokuNoStart contains runes which must not start a line, such as
+	// closing brackets, punctuation and small kana.
+	kinsokuNoStart = "!),.:;?]}¢°’”‰′″℃、。々〉》」』】〕〗〙〛〞〟ゝゞ゠・ヽヾ" +
+		"ぁぃぅぇぉっゃゅょゎゕゖァィゥェォッャュョヮヵヶㇰㇱㇲㇳㇴㇵㇶㇷㇸㇹㇺㇻㇼㇽㇾㇿー〜" +
+		"！％），．：；？］｝｡｣､･ｧｨｩｪｫｬｭｮｯｰ"
+
+	// kinsokuNoEnd contains runes which must not end a line, such as opening
+	// brackets and quotation marks.
+	kinsokuNoEnd = "([{£¥‘“〈《「『【〔〖〘〚〝＄（［｛｢￡￥"
+)
+
+// isCJK reports whether r belongs to a script that doesn't separate words
+// with spaces, meaning a line may be broken before or after it.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana) ||
+		(r >= 0x3000 && r <= 0x303f) || // CJK symbols and punctuation
+		(r >= 0xff00 && r <= 0xffef) // halfwidth and fullwidth forms
+}
+
+// canBreakCJK reports whether a line may be broken between the runes a and b,
+// following the kinsoku shori rules for Japanese and Chinese text.
+func canBreakCJK(a, b rune) bool {
+	if !isCJK(a) && !isCJK(b) {
+		return false
+	}
+	return !strings.ContainsRune(kinsokuNoEnd, a) &&
+		!strings.ContainsRune(kinsokuNoStart, b)
+}
@@ -0,0 +1,53 @@
+package wordwrap
+
+import (
+	"math"
+
+	"github.com/muesli/reflow/ansi"
+)
+
+// Truncated reports whether any content got dropped, see MaxLines.
+func (w *WordWrap) Truncated() bool {
+	return w.clamped.Truncated()
+}
+
+// lastLine reports whether the current line is the last one permitted by
+// MaxLines.
+func (w *WordWrap) lastLine() bool {
+	return w.MaxLines > 0 && w.line+1 >= w.MaxLines
+}
+
+// drop discards a rune following the last line permitted by MaxLines. Any
+// visible content clamps that line.
+func (w *WordWrap) drop(c rune) {
+	if w.clamped.Drop(c) {
+		w.clamp()
+	}
+}
+
+// clamp drops all content following the current line, which gets Tail put on
+// its end instead.
+func (w *WordWrap) clamp() {
+	w.full = true
+	w.word.Reset()
+	w.space.Reset()
+	w.spaceWidth = 0
+	w.spaceAnchors = nil
+	if w.ended {
+		w.buf.Truncate(w.endOff)
+	}
+
+	width := w.limit()
+	if width <= 0 {
+		width = math.MaxInt32
+	}
+	w.clamped.Track(w.buf.Bytes()[:w.lineStart])
+	line := w.clamped.Line(string(w.buf.Bytes()[w.lineStart:]), width, w.Tail)
+
+	w.buf.Truncate(w.lineStart)
+	_, _ = w.buf.WriteString(line)
+	w.lineLen = ansi.PrintableRuneWidth(line)
+
+	// the line is reset already, see PreserveStyles
+	w.restore = true
+}
@@ -0,0 +1,134 @@
+package wordwrap
+
+import (
+	"strings"
+	"unicode"
+)
+
+// refill joins the lines of every paragraph found in b before word-wrapping
+// them. Incomplete lines are held back until they get terminated, or final is
+// set at the end of the input.
+func (w *WordWrap) refill(b []byte, final bool) error {
+	w.refillBuf = append(w.refillBuf, b...)
+
+	for len(w.refillBuf) > 0 {
+		s := string(w.refillBuf)
+		start, end := w.lineEnd(s, final)
+		if start < 0 {
+			if !final {
+				return nil
+			}
+			start, end = len(s), len(s)
+		}
+
+		if err := w.refillLine(s[:start], s[start:end]); err != nil {
+			return err
+		}
+		w.refillBuf = w.refillBuf[end:]
+		w.refillPos += end
+	}
+
+	if final {
+		// terminate the last paragraph like the input did
+		w.indent = ""
+		if w.inPara && w.eol != "" {
+			w.srcPos = w.eolPos
+			if err := w.write(w.eol); err != nil {
+				return err
+			}
+		}
+		w.inPara = false
+		w.srcPos = w.refillPos
+	}
+	return nil
+}
+
+// lineEnd returns the byte offsets at which the line ending of the first line
+// in s starts and ends, or -1 if the line isn't terminated yet.
+func (w *WordWrap) lineEnd(s string, final bool) (int, int) {
+	for i, c := range s {
+		if !inGroup(w.Newline, c) {
+			continue
+		}
+
+		switch {
+		case c == '\n' && i > 0 && s[i-1] == '\r':
+			return i - 1, i + 1
+		case c == '\r' && strings.HasPrefix(s[i+1:], "\n"):
+			return i, i + 2
+		case c == '\r' && i+1 == len(s) && !final:
+			// the CR may turn out to be part of a CRLF line ending
+			return -1, -1
+		}
+		return i, i + 1
+	}
+	return -1, -1
+}
+
+// refillLine adds a single line to the current paragraph, or starts a new one.
+// The line ending of every line is held back until the next line tells
+// whether it joins the paragraph.
+func (w *WordWrap) refillLine(line, eol string) error {
+	pos := w.refillPos
+	text := strings.TrimRightFunc(line, unicode.IsSpace)
+	body := strings.TrimLeftFunc(text, unicode.IsSpace)
+	indent := text[:len(text)-len(body)]
+
+	var (
+		parts   []string
+		offsets []int
+		content int // index of the first part within the paragraph
+	)
+	switch {
+	case body == "":
+		// blank lines end the paragraph and are kept as they are, minus
+		// any whitespace
+		if w.inPara {
+			parts = append(parts, w.eol)
+			offsets = append(offsets, w.eolPos)
+		}
+		if eol != "" {
+			parts = append(parts, eol)
+			offsets = append(offsets, pos+len(line))
+		}
+		w.inPara = false
+		w.eol = ""
+		content = len(parts)
+
+	case w.inPara && indent == w.paraIndent:
+		// a continuation of the paragraph
+		parts = append(parts, " ", body)
+		offsets = append(offsets, w.eolPos, pos+len(indent))
+
+	case w.inPara:
+		// a change of indentation starts a new paragraph
+		parts = append(parts, w.eol, text)
+		offsets = append(offsets, w.eolPos, pos)
+		content = 1
+
+	default:
+		parts = append(parts, text)
+		offsets = append(offsets, pos)
+	}
+
+	for i, part := range parts {
+		// wrapped lines keep the paragraph's indentation
+		w.indent = ""
+		if i >= content {
+			w.indent = indent
+		}
+
+		w.srcPos = offsets[i]
+		if err := w.write(part); err != nil {
+			return err
+		}
+	}
+
+	if body != "" {
+		w.inPara = true
+		w.paraIndent = indent
+		w.eol = eol
+		w.eolPos = pos + len(line)
+	}
+	return nil
+}
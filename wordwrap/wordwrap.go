@@ -2,10 +2,49 @@ package wordwrap
 
 import (
 	"bytes"
+	"io"
+	"math"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/mattn/go-runewidth"
 	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/internal/wrapping"
+	"github.com/muesli/reflow/position"
+)
+
+// Alignment describes how the words of a line are laid out within its limit.
+type Alignment int
+
+// Available alignments.
+const (
+	// AlignLeft keeps words separated by their original whitespace.
+	AlignLeft Alignment = iota
+	// AlignJustify spreads additional spaces across the gaps between words,
+	// so every line but a paragraph's last spans the entire limit.
+	AlignJustify
+)
+
+// LineEnding describes the line break sequence written to the output.
+type LineEnding int
+
+// Available line endings.
+const (
+	// LineEndingDefault terminates lines with "\n", leaving any CR of the
+	// input as it is.
+	LineEndingDefault LineEnding = iota
+	// LineEndingLF terminates lines with "\n", converting CRLF line endings
+	// of the input.
+	LineEndingLF
+	// LineEndingCRLF terminates lines with "\r\n".
+	LineEndingCRLF
+	// LineEndingCR terminates lines with "\r".
+	LineEndingCR
+	// LineEndingPreserve keeps the line endings found in the input. Line
+	// breaks added by wrapping use the line ending of the input line they
+	// break, as far as it's part of the same Write.
+	LineEndingPreserve
 )
 
 var (
@@ -21,13 +60,127 @@ type WordWrap struct {
 	Breakpoints  []rune
 	Newline      []rune
 	KeepNewlines bool
+	Alignment    Alignment
+	LineEnding   LineEnding
+
+	// LimitFunc, if set, returns the limit of every line by its index,
+	// counting from zero, in place of Limit. Lines with a non-positive
+	// limit don't get wrapped.
+	LimitFunc func(line int) int
+
+	// TabWidth expands tabs to tab stops every TabWidth columns. If it's
+	// zero, tabs count as a single column and are kept as they are.
+	TabWidth int
+
+	// KeepTabs writes tabs as they are, rather than expanding them to
+	// spaces. They still take up the width up to the next tab stop.
+	KeepTabs bool
+
+	// BreakFunc decides where lines may be broken within words, in addition
+	// to Breakpoints, see BreakProse, BreakPaths, BreakDotted and
+	// BreakCamelCase.
+	BreakFunc BreakFunc
+
+	// BreakCJK allows breaking lines between Chinese and Japanese
+	// characters, which aren't separated by spaces. Kinsoku shori rules
+	// are followed, so lines never start with closing punctuation or end
+	// with opening brackets.
+	BreakCJK bool
+
+	// Bidi reorders every wrapped line from logical to visual order, so
+	// right-to-left scripts like Hebrew or Arabic display correctly. Lines
+	// are still broken in logical order.
+	Bidi bool
 
-	buf   bytes.Buffer
-	space bytes.Buffer
-	word  ansi.Buffer
+	// Balance narrows every paragraph as far as possible without adding
+	// lines to it, so all of its lines come out about the same width.
+	Balance bool
+
+	// MinLastLineWords pulls words down from the second to last line of a
+	// paragraph, until its last line has at least this many words. This
+	// avoids widows, unless the paragraph would need another line.
+	MinLastLineWords int
+
+	// Refill joins hard-wrapped paragraphs before wrapping them again.
+	// Single line breaks become spaces, while blank lines and changes in
+	// indentation start a new paragraph. It takes precedence over
+	// KeepNewlines.
+	Refill bool
+
+	// MaxLines stops wrapping after this many lines, dropping any following
+	// content. Tail is put on the last line if content got dropped, see
+	// Truncated.
+	MaxLines int
+	Tail     string
+
+	// PreserveStyles resets all styles at the end of every line and restores
+	// them at the start of the next, so that each line is self-contained and
+	// backgrounds don't bleed into line breaks.
+	PreserveStyles bool
+
+	// RecordLines keeps a table of all output lines and the source text
+	// they were made of, see Lines.
+	RecordLines bool
+
+	buf        bytes.Buffer
+	runes      wrapping.Runes
+	space      bytes.Buffer
+	spaceWidth int
+	word       ansi.Buffer
+	forward    io.Writer
+	ansiWriter *ansi.Writer
+
+	line       int
+	lineLen    int
+	lineStart  int
+	lastRune   rune
+	endings    wrapping.Endings
+	bidiStyle  string
+	paragraph  *paragraph
+	paragraphs []*paragraph
+	bidiCR     bool
+	cr         bool
+	ansi       bool
+	restore    bool
+	hang       bool
+	full       bool
+	ended      bool
+	endOff     int
+	clamped    wrapping.Clamp
+
+	// paragraph state, see Balance
+	para      []segment
+	paraLine  int
+	paraLimit func(line int) int
+
+	// paragraph state, see Refill
+	refillBuf  []byte
+	refillPos  int
+	inPara     bool
+	paraIndent string
+	indent     string
+	eol        string
+	eolPos     int
+
+	// source offsets, see RecordLines
+	lines                []position.Line
+	srcPos               int
+	pos                  int
+	flushed              int
+	wordStart, wordEnd   int
+	spaceStart, spaceEnd int
+	lineSrc              bool
+	lineSrcStart         int
+	lineSrcEnd           int
+	anchors              []anchor
+	spaceAnchors         []anchor
+}
 
-	lineLen int
-	ansi    bool
+// anchor is a source offset along with the output offset it got written to,
+// see RecordLines.
+type anchor struct {
+	src int
+	out int
 }
 
 // NewWriter returns a new instance of a word-wrapping writer, initialized with
@@ -41,6 +194,15 @@ func NewWriter(limit int) *WordWrap {
 	}
 }
 
+// NewWriterPipe returns a new instance of a word-wrapping writer, initialized
+// with default settings. Every completed line is written to forward as soon as
+// it can't change anymore, rather than accumulating the entire result.
+func NewWriterPipe(forward io.Writer, limit int) *WordWrap {
+	w := NewWriter(limit)
+	w.forward = forward
+	return w
+}
+
 // Bytes is shorthand for declaring a new default WordWrap instance,
 // used to immediately word-wrap a byte slice.
 func Bytes(b []byte, limit int) []byte {
@@ -58,24 +220,418 @@ func String(s string, limit int) string {
 }
 
 func (w *WordWrap) addSpace() {
-	w.lineLen += w.space.Len()
-	_, _ = w.buf.Write(w.space.Bytes())
+	if w.space.Len() > 0 {
+		w.addSource(w.spaceStart, w.spaceEnd)
+	}
+	w.lineLen += w.spaceWidth
+	w.writeContent(w.space.Bytes())
+	w.anchorSpace()
 	w.space.Reset()
+	w.spaceWidth = 0
+	w.spaceAnchors = nil
 }
 
 func (w *WordWrap) addWord() {
 	if w.word.Len() > 0 {
 		w.addSpace()
+		w.addSource(w.wordStart, w.wordEnd)
 		w.lineLen += w.word.PrintableRuneWidth()
-		_, _ = w.buf.Write(w.word.Bytes())
+		w.writeContent(w.word.Bytes())
+		w.anchor(w.wordStart, w.word.Len())
 		w.word.Reset()
+		w.lastRune = 0
 	}
 }
 
 func (w *WordWrap) addNewLine() {
-	_, _ = w.buf.WriteRune('\n')
+	if w.lastLine() {
+		// any following content gets dropped, see MaxLines, while the
+		// line ending only is if any does
+		w.full = true
+		w.ended = true
+		w.endOff = w.buf.Len()
+		w.resetStyles()
+		_, _ = w.buf.WriteString(w.newline())
+		w.space.Reset()
+		w.spaceWidth = 0
+		w.spaceAnchors = nil
+		return
+	}
+
+	w.resetStyles()
+	w.addLine()
+	_, _ = w.buf.WriteString(w.newline())
+	w.endLine()
+	w.line++
 	w.lineLen = 0
+	w.lineStart = w.buf.Len()
 	w.space.Reset()
+	w.spaceWidth = 0
+	w.spaceAnchors = nil
+
+	// continue the indentation of refilled paragraphs
+	_, _ = w.buf.WriteString(w.indent)
+	w.lineLen += runewidth.StringWidth(w.indent)
+}
+
+// newline returns the line ending to be written for the next line break.
+func (w *WordWrap) newline() string {
+	switch w.LineEnding {
+	case LineEndingCRLF:
+		return "\r\n"
+	case LineEndingCR:
+		return "\r"
+	case LineEndingPreserve:
+		if e := w.endings.Preserved(); e != "" {
+			return e
+		}
+	}
+	return "\n"
+}
+
+// crlf reports whether CRLF line endings of the input are recognised as
+// such, see LineEndingDefault.
+func (w *WordWrap) crlf() bool {
+	return w.LineEnding != LineEndingDefault
+}
+
+// writeContent writes content of the current line to the buffer. With
+// PreserveStyles enabled, the styles active at the end of the previous line
+// get restored first.
+func (w *WordWrap) writeContent(b []byte) {
+	if !w.PreserveStyles {
+		_, _ = w.buf.Write(b)
+		return
+	}
+
+	if w.ansiWriter == nil {
+		w.ansiWriter = &ansi.Writer{Forward: &w.buf}
+	}
+	if w.restore {
+		w.ansiWriter.RestoreAnsi()
+		w.restore = false
+	}
+	_, _ = w.ansiWriter.Write(b)
+}
+
+// resetStyles resets all styles at the end of a line, so they can't bleed
+// into the following line, see PreserveStyles.
+func (w *WordWrap) resetStyles() {
+	if w.ansiWriter == nil {
+		return
+	}
+
+	// lines without any content don't get restored, so they don't need to
+	// be reset either
+	if !w.restore {
+		w.ansiWriter.ResetAnsi()
+	}
+	w.restore = true
+}
+
+// addSource extends the current line by the given range of source offsets.
+func (w *WordWrap) addSource(start, end int) {
+	if !w.lineSrc {
+		w.lineSrc = true
+		w.lineSrcStart = start
+	}
+	w.lineSrcEnd = end
+}
+
+// addLine records the current line in the line table.
+func (w *WordWrap) addLine() {
+	if !w.RecordLines {
+		return
+	}
+	if !w.lineSrc {
+		// lines without any source end where the source of the following
+		// line starts, which is the word pending if the limit broke them
+		pos := w.pos
+		if w.word.Len() > 0 {
+			pos = w.wordStart
+		}
+		w.lineSrcStart = pos
+		w.lineSrcEnd = pos
+	}
+
+	line := w.buf.Bytes()[w.lineStart:]
+	w.lines = append(w.lines, position.Line{
+		SourceStart: w.lineSrcStart,
+		SourceEnd:   w.lineSrcEnd,
+		OutputStart: w.flushed + w.lineStart,
+		OutputEnd:   w.flushed + w.lineStart + len(line),
+		Width:       ansi.PrintableRuneWidth(string(line)),
+		Anchors:     w.columns(line),
+	})
+	w.lineSrc = false
+	w.anchors = nil
+}
+
+// anchor remembers that the source at pos just got written to the last n
+// bytes of the output, see RecordLines.
+func (w *WordWrap) anchor(pos, n int) {
+	if w.RecordLines {
+		w.anchors = append(w.anchors, anchor{src: pos, out: w.flushed + w.buf.Len() - n})
+	}
+}
+
+// anchorSpace anchors the space buffer, which just got written to the output,
+// along with the tabs in it.
+func (w *WordWrap) anchorSpace() {
+	n := w.space.Len()
+	if n == 0 {
+		return
+	}
+
+	w.anchor(w.spaceStart, n)
+	for _, a := range w.spaceAnchors {
+		w.anchor(a.src, n-a.out)
+	}
+}
+
+// columns returns the anchors of the current line, which makes up line of the
+// output, by the columns their output offsets are displayed at.
+func (w *WordWrap) columns(line []byte) []position.Anchor {
+	var (
+		anchors []position.Anchor
+		col     int
+		i       int
+		esc     bool
+	)
+	for _, a := range w.anchors {
+		for off := a.out - w.flushed - w.lineStart; i < off && i < len(line); {
+			c, size := utf8.DecodeRune(line[i:])
+			switch {
+			case c == ansi.Marker:
+				esc = true
+			case esc:
+				if ansi.IsTerminator(c) {
+					esc = false
+				}
+			case c == '\t' && w.TabWidth > 0:
+				col += w.TabWidth - col%w.TabWidth
+			default:
+				col += runewidth.RuneWidth(c)
+			}
+			i += size
+		}
+		anchors = append(anchors, position.Anchor{Source: a.src, Col: col})
+	}
+
+	if len(anchors) == 1 && anchors[0] == (position.Anchor{Source: w.lineSrcStart}) {
+		// the line is displayed as is
+		return nil
+	}
+	return anchors
+}
+
+// writeSpace adds a whitespace rune found at pos to the space buffer. Tabs
+// are expanded to the next tab stop, see TabWidth.
+func (w *WordWrap) writeSpace(c rune, pos, size int) {
+	if w.space.Len() == 0 {
+		w.spaceStart = pos
+	}
+	w.spaceEnd = pos + size
+
+	if c != '\t' || w.TabWidth <= 0 {
+		_, _ = w.space.WriteRune(c)
+		w.spaceWidth += spaceCells(c)
+		return
+	}
+
+	if w.RecordLines {
+		w.spaceAnchors = append(w.spaceAnchors, anchor{src: pos, out: w.space.Len()})
+	}
+
+	n := w.TabWidth - (w.lineLen+w.spaceWidth)%w.TabWidth
+	w.spaceWidth += n
+	if w.KeepTabs {
+		_, _ = w.space.WriteRune(c)
+	} else {
+		_, _ = w.space.WriteString(strings.Repeat(" ", n))
+	}
+
+	if w.RecordLines {
+		w.spaceAnchors = append(w.spaceAnchors, anchor{src: pos + size, out: w.space.Len()})
+	}
+}
+
+// writeWord adds a rune found at pos to the word buffer.
+func (w *WordWrap) writeWord(c rune, pos, size int) {
+	if w.word.Len() == 0 {
+		w.wordStart = pos
+	}
+	w.wordEnd = pos + size
+	_, _ = w.word.WriteRune(c)
+}
+
+// writeBytes adds b to the current word as it is, see writeWord.
+func (w *WordWrap) writeBytes(b []byte, pos int) {
+	if w.word.Len() == 0 {
+		w.wordStart = pos
+	}
+	w.wordEnd = pos + len(b)
+	_, _ = w.word.Write(b)
+}
+
+// Lines returns the table of output lines recorded so far. Lines are only
+// recorded if RecordLines is enabled and Limit is positive or LimitFunc
+// is set. Output offsets refer to the output before any bidirectional
+// reordering.
+func (w *WordWrap) Lines() []position.Line {
+	return w.lines
+}
+
+// limit returns the limit of the current line.
+func (w *WordWrap) limit() int {
+	if w.paraLimit != nil {
+		return w.paraLimit(w.line - w.paraLine)
+	}
+	return w.lineLimit(w.line)
+}
+
+// lineLimit returns the limit of the given line, as configured by Limit and
+// LimitFunc.
+func (w *WordWrap) lineLimit(line int) int {
+	if w.LimitFunc == nil {
+		return w.Limit
+	}
+	if l := w.LimitFunc(line); l > 0 {
+		return l
+	}
+	return math.MaxInt32
+}
+
+// flush writes all completed lines to the forward writer, if there is one.
+func (w *WordWrap) flush() error {
+	if w.forward == nil || w.lineStart == 0 {
+		return nil
+	}
+
+	b := w.buf.Next(w.lineStart)
+	w.flushed += len(b)
+	w.lineStart = 0
+	if w.MaxLines > 0 {
+		w.clamped.Track(b)
+	}
+	if w.Bidi {
+		b = []byte(w.reorder(string(b)))
+	}
+
+	_, err := w.forward.Write(b)
+	return err
+}
+
+// justify widens the gaps between the words of the current line, so that it
+// spans the entire limit. Extra spaces are distributed evenly, with the
+// leftmost gaps receiving the remainder.
+func (w *WordWrap) justify() {
+	line := string(w.buf.Bytes()[w.lineStart:])
+
+	var (
+		width   int
+		gaps    []int // byte offsets at which interior gaps end
+		inGap   bool
+		started bool
+		ansi    bool
+	)
+	for i, c := range line {
+		if c == '\x1B' {
+			if inGap {
+				gaps = append(gaps, i)
+				inGap = false
+			}
+			ansi = true
+		} else if ansi {
+			if (c >= 0x40 && c <= 0x5a) || (c >= 0x61 && c <= 0x7a) {
+				ansi = false
+			}
+		} else if c == '\t' && w.TabWidth > 0 {
+			// a kept tab reaches up to its tab stop, so widening the gaps
+			// in front of it would only change its width
+			width += w.TabWidth - width%w.TabWidth
+			gaps = gaps[:0]
+			inGap = started
+		} else if unicode.IsSpace(c) {
+			width += spaceCells(c)
+			inGap = started
+		} else {
+			if inGap {
+				gaps = append(gaps, i)
+				inGap = false
+			}
+			started = true
+			width += runewidth.RuneWidth(c)
+		}
+	}
+
+	extra := w.limit() - width
+	if extra <= 0 || len(gaps) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	var last int
+	for i, pos := range gaps {
+		n := extra / len(gaps)
+		if i < extra%len(gaps) {
+			n++
+		}
+
+		// insert the additional spaces right before the next word
+		_, _ = b.WriteString(line[last:pos])
+		_, _ = b.WriteString(strings.Repeat(" ", n))
+		w.shiftAnchors(w.flushed+w.lineStart+b.Len()-n, n)
+		last = pos
+	}
+	_, _ = b.WriteString(line[last:])
+
+	w.buf.Truncate(w.lineStart)
+	_, _ = w.buf.WriteString(b.String())
+}
+
+// shiftAnchors moves the anchors at or after the output offset off by n
+// bytes, as content got inserted there.
+func (w *WordWrap) shiftAnchors(off, n int) {
+	for i := range w.anchors {
+		if w.anchors[i].out >= off {
+			w.anchors[i].out += n
+		}
+	}
+}
+
+// cells returns the cell width of s, a line of output, with kept tabs
+// reaching up to their tab stop.
+func (w *WordWrap) cells(s string) int {
+	var n int
+	var ansi bool
+
+	for _, c := range s {
+		if c == '\x1B' {
+			// ANSI escape sequence
+			ansi = true
+		} else if ansi {
+			if (c >= 0x40 && c <= 0x5a) || (c >= 0x61 && c <= 0x7a) {
+				// ANSI sequence terminated
+				ansi = false
+			}
+		} else if c == '\t' && w.TabWidth > 0 {
+			n += w.TabWidth - n%w.TabWidth
+		} else {
+			n += runewidth.RuneWidth(c)
+		}
+	}
+
+	return n
+}
+
+// spaceCells returns the cell width of a whitespace rune. Control characters
+// like CR count as a single cell, just like tabs without a TabWidth.
+func spaceCells(c rune) int {
+	if n := runewidth.RuneWidth(c); n > 0 {
+		return n
+	}
+	return 1
 }
 
 func inGroup(a []rune, c rune) bool {
@@ -89,74 +645,201 @@ func inGroup(a []rune, c rune) bool {
 
 // Write is used to write more content to the word-wrap buffer.
 func (w *WordWrap) Write(b []byte) (int, error) {
-	if w.Limit == 0 {
-		return w.buf.Write(b)
+	n := len(b)
+	if err := w.process(w.runes.Complete(b)); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// process refills or word-wraps b, which only holds complete runes.
+func (w *WordWrap) process(b []byte) error {
+	if w.Refill {
+		return w.refill(b, false)
 	}
+	return w.write(string(b))
+}
 
-	s := string(b)
-	if !w.KeepNewlines {
-		s = strings.Replace(strings.TrimSpace(s), "\n", " ", -1)
+// write word-wraps s, which starts at the source offset srcPos.
+func (w *WordWrap) write(s string) error {
+	if w.Balance || w.MinLastLineWords > 0 {
+		return w.collect(s)
 	}
+	return w.wrap(s)
+}
+
+// wrap word-wraps s right away, see write.
+func (w *WordWrap) wrap(s string) error {
+	if w.Limit == 0 && w.LimitFunc == nil && w.MaxLines == 0 {
+		_, _ = w.buf.WriteString(s)
+		if w.forward != nil {
+			w.lineStart = bytes.LastIndexAny(w.buf.Bytes(), "\r\n") + 1
+		}
+		if err := w.flush(); err != nil {
+			return err
+		}
+		w.srcPos += len(s)
+		return nil
+	}
+
+	start, end := 0, len(s)
+	keepNewlines := w.KeepNewlines || w.Refill
+	if !keepNewlines {
+		start = len(s) - len(strings.TrimLeftFunc(s, unicode.IsSpace))
+		end = start + len(strings.TrimRightFunc(s[start:], unicode.IsSpace))
+	}
+
+	for i, c := range s[start:end] {
+		i += start
+		_, size := utf8.DecodeRuneInString(s[i:])
+		w.pos = w.srcPos + i
+		// look for the line ending of the current input line, which the
+		// LF of a CRLF line ending doesn't belong to anymore
+		if w.LineEnding == LineEndingPreserve && (c != '\n' || !w.cr) {
+			w.endings.LookAhead(s[i:end], i == start, w.Newline)
+		}
+
+		if !keepNewlines {
+			if c == '\r' && strings.HasPrefix(s[i+1:], "\n") && w.crlf() {
+				continue
+			}
+			if c == '\n' {
+				c = ' '
+			}
+		}
+
+		if w.full {
+			w.drop(c)
+			continue
+		}
+
+		cr := w.cr
+		w.cr = false
 
-	for _, c := range s {
 		if c == '\x1B' {
 			// ANSI escape sequence
-			_, _ = w.word.WriteRune(c)
+			w.writeWord(c, w.pos, size)
 			w.ansi = true
 		} else if w.ansi {
-			_, _ = w.word.WriteRune(c)
+			w.writeWord(c, w.pos, size)
 			if (c >= 0x40 && c <= 0x5a) || (c >= 0x61 && c <= 0x7a) {
 				// ANSI sequence terminated
 				w.ansi = false
 			}
 		} else if inGroup(w.Newline, c) {
+			w.settle()
+			if c == '\n' && cr {
+				// a CRLF line ending already broke the line at its CR
+				if w.LineEnding == LineEndingPreserve {
+					_, _ = w.buf.WriteRune(c)
+					w.endings.Last = "\r\n"
+					w.lineStart = w.buf.Len()
+				}
+				continue
+			}
+
+			w.endings.Last = string(c)
+			w.cr = c == '\r' && w.crlf()
+			if c == '\n' && bytes.HasSuffix(w.space.Bytes(), []byte{'\r'}) && w.crlf() {
+				// the CR of a CRLF line ending ended up as whitespace
+				w.space.Truncate(w.space.Len() - 1)
+				w.spaceWidth--
+				w.spaceEnd--
+				w.endings.Last = "\r\n"
+			}
+
 			// end of current line
 			// see if we can add the content of the space buffer to the current line
 			if w.word.Len() == 0 {
-				if w.lineLen+w.space.Len() > w.Limit {
+				if w.lineLen+w.spaceWidth > w.limit() {
 					w.lineLen = 0
-				} else {
+				} else if w.space.Len() > 0 {
 					// preserve whitespace
-					_, _ = w.buf.Write(w.space.Bytes())
+					w.addSource(w.spaceStart, w.spaceEnd)
+					w.writeContent(w.space.Bytes())
+					w.anchorSpace()
 				}
 				w.space.Reset()
+				w.spaceWidth = 0
+				w.spaceAnchors = nil
 			}
 
 			w.addWord()
+			w.endings.EndLine()
 			w.addNewLine()
+			w.endParagraph()
 		} else if unicode.IsSpace(c) {
 			// end of current word
+			w.settle()
 			w.addWord()
-			_, _ = w.space.WriteRune(c)
+			w.writeSpace(c, w.pos, size)
 		} else if inGroup(w.Breakpoints, c) {
 			// valid breakpoint
+			w.settle()
 			w.addSpace()
 			w.addWord()
-			_, _ = w.buf.WriteRune(c)
+			w.addSource(w.pos, w.pos+size)
+			w.writeContent([]byte(string(c)))
+			w.anchor(w.pos, size)
 		} else {
 			// any other character
-			_, _ = w.word.WriteRune(c)
+			w.trackDirection(c)
+			w.addRune(c, size)
+		}
 
-			// add a line break if the current word would exceed the line's
-			// character limit
-			if w.lineLen+w.space.Len()+w.word.PrintableRuneWidth() > w.Limit &&
-				w.word.PrintableRuneWidth() < w.Limit {
-				w.addNewLine()
-			}
+		if err := w.flush(); err != nil {
+			return err
 		}
 	}
 
-	return len(b), nil
+	w.srcPos += len(s)
+	return nil
 }
 
 // Close will finish the word-wrap operation. Always call it before trying to
 // retrieve the final result.
 func (w *WordWrap) Close() error {
+	if w.Refill {
+		if err := w.refill(nil, true); err != nil {
+			return err
+		}
+	}
+	if err := w.wrapParagraph(); err != nil {
+		return err
+	}
+
+	w.settle()
+	if b := w.runes.Rest(); len(b) > 0 {
+		// an incomplete rune at the very end of the input, which gets
+		// passed through unchanged
+		if w.full {
+			w.drop(utf8.RuneError)
+		} else {
+			w.writeBytes(b, w.srcPos)
+		}
+		w.srcPos += len(b)
+	}
 	w.addWord()
+	w.resetStyles()
+	w.pos = w.srcPos
+	w.addLine()
+
+	if w.forward != nil {
+		w.lineStart = w.buf.Len()
+		return w.flush()
+	}
+
+	if w.Bidi {
+		s := w.reorder(w.buf.String())
+		w.buf.Reset()
+		_, _ = w.buf.WriteString(s)
+	}
 	return nil
 }
 
-// Bytes returns the word-wrapped result as a byte slice.
+// Bytes returns the word-wrapped result as a byte slice. Writers created by
+// NewWriterPipe return an empty result, as all output went to the forward
+// writer.
 func (w *WordWrap) Bytes() []byte {
 	return w.buf.Bytes()
 }
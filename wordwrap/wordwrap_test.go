@@ -1,7 +1,12 @@
 package wordwrap
 
 import (
+	"bytes"
+	"errors"
+	"reflect"
 	"testing"
+
+	"github.com/muesli/reflow/position"
 )
 
 func TestWordWrap(t *testing.T) {
@@ -153,3 +158,991 @@ func TestWordWrapString(t *testing.T) {
 		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
 	}
 }
+
+func TestWordWrapJustify(t *testing.T) {
+	tt := []struct {
+		Input    string
+		Expected string
+		Limit    int
+	}{
+		// Extra spaces are distributed evenly, leftmost gaps first:
+		{
+			"aa b c ddd eeee",
+			"aa   b  c\nddd eeee",
+			9,
+		},
+		// The last line of a paragraph is not justified:
+		{
+			"foo bar baz\nqux quux\n",
+			"foo   bar\nbaz\nqux quux\n",
+			9,
+		},
+		// Leading whitespace is kept as is:
+		{
+			" a b c",
+			" a  b\nc",
+			5,
+		},
+		// Lines with a single word can't be justified:
+		{
+			"foobar baz",
+			"foobar\nbaz",
+			8,
+		},
+		// Double-width runes fill their cells:
+		{
+			"你好 a b",
+			"你好  a\nb",
+			7,
+		},
+		// Wide whitespace is measured by its cells:
+		{
+			"aa\u3000bb cc dd ee",
+			"aa\u3000bb cc\ndd ee",
+			9,
+		},
+		{
+			"aa\u3000b c dd",
+			"aa\u3000 b  c\ndd",
+			9,
+		},
+		// ANSI sequences don't affect the width and stay attached to words:
+		{
+			"\x1B[31mfoo\x1B[0m \x1B[32mbar\x1B[0m baz",
+			"\x1B[31mfoo\x1B[0m  \x1B[32mbar\x1B[0m\nbaz",
+			8,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.Alignment = AlignJustify
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWordWrapCJK(t *testing.T) {
+	tt := []struct {
+		Input    string
+		Expected string
+		Limit    int
+	}{
+		// Lines are broken between ideographs:
+		{
+			"日本語の文章です",
+			"日本語\nの文章\nです",
+			6,
+		},
+		// Closing punctuation and small kana never start a line:
+		{
+			"日本語。文章ですっ",
+			"日本\n語。文\n章で\nすっ",
+			6,
+		},
+		// Opening brackets never end a line:
+		{
+			"日本「語」",
+			"日本\n「語」",
+			6,
+		},
+		// Latin words aren't broken:
+		{
+			"Go言語 foobar",
+			"Go言\n語\nfoobar",
+			5,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.BreakCJK = true
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWordWrapBidi(t *testing.T) {
+	tt := []struct {
+		Input    string
+		Expected string
+		Limit    int
+	}{
+		// Left-to-right text is unaffected:
+		{
+			"foo bar",
+			"foo\nbar",
+			4,
+		},
+		// Lines are broken in logical order, then reordered:
+		{
+			"אבג דהו זחט",
+			"והד גבא\nטחז",
+			7,
+		},
+		// Mixed directions:
+		{
+			"foo אבג bar",
+			"foo גבא\nbar",
+			7,
+		},
+		// Wrapped lines keep the direction of their paragraph:
+		{
+			"שלום עולם Go מצוין",
+			"םלוע םולש\nןיוצמ Go",
+			10,
+		},
+		{
+			"Go מצוין\nשלום Go",
+			"Go ןיוצמ\nGo םולש",
+			10,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.Bidi = true
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWordWrapLineEnding(t *testing.T) {
+	tt := []struct {
+		Input      string
+		Expected   string
+		Limit      int
+		Newline    []rune
+		LineEnding LineEnding
+	}{
+		// CRs of the input are left as they are by default:
+		{
+			"foo bar\r\nbaz",
+			"foo\nbar\r\nbaz",
+			4,
+			[]rune{'\n'},
+			LineEndingDefault,
+		},
+		{
+			"foo\r\nbar\rbaz",
+			"foo\n\nbar\nbaz",
+			4,
+			[]rune{'\r', '\n'},
+			LineEndingDefault,
+		},
+		// CRLF line endings are converted:
+		{
+			"foo bar\r\nbaz",
+			"foo\nbar\nbaz",
+			4,
+			[]rune{'\n'},
+			LineEndingLF,
+		},
+		{
+			"foo bar\nbaz",
+			"foo\r\nbar\r\nbaz",
+			4,
+			[]rune{'\n'},
+			LineEndingCRLF,
+		},
+		{
+			"foo bar\nbaz",
+			"foo\rbar\rbaz",
+			4,
+			[]rune{'\n'},
+			LineEndingCR,
+		},
+		// Line endings of the input are preserved and used for line breaks:
+		{
+			"foo\r\nbar baz",
+			"foo\r\nbar\r\nbaz",
+			4,
+			[]rune{'\n'},
+			LineEndingPreserve,
+		},
+		// Line breaks use the line ending of the line they break:
+		{
+			"a b c\r\nd e",
+			"a b\r\nc\r\nd e",
+			3,
+			[]rune{'\n'},
+			LineEndingPreserve,
+		},
+		{
+			"a b c\nd e f\r\n",
+			"a b\nc\nd e\r\nf\r\n",
+			3,
+			[]rune{'\n'},
+			LineEndingPreserve,
+		},
+		{
+			"a b c\rd e f\r\n",
+			"a b\rc\rd e\r\nf\r\n",
+			3,
+			[]rune{'\r', '\n'},
+			LineEndingPreserve,
+		},
+		// CRLF is a single line break, even if CR is a newline itself:
+		{
+			"foo\r\nbar\rbaz",
+			"foo\nbar\nbaz",
+			4,
+			[]rune{'\r', '\n'},
+			LineEndingLF,
+		},
+		{
+			"foo\r\nbar\rbaz",
+			"foo\r\nbar\rbaz",
+			4,
+			[]rune{'\r', '\n'},
+			LineEndingPreserve,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.Newline = tc.Newline
+		f.LineEnding = tc.LineEnding
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWordWrapLimitFunc(t *testing.T) {
+	t.Parallel()
+
+	f := NewWriter(0)
+	f.LimitFunc = func(line int) int {
+		if line < 2 {
+			return 6
+		}
+		return 12
+	}
+	_, _ = f.Write([]byte("The quick brown fox jumps over the \x1B[1mlazy\x1B[0m dog-house"))
+	_ = f.Close()
+
+	expected := "The\nquick\nbrown fox\njumps over\nthe \x1B[1mlazy\x1B[0m dog-\nhouse"
+	if f.String() != expected {
+		t.Errorf("expected:\n\n`%q`\n\nActual Output:\n\n`%q`", expected, f.String())
+	}
+}
+
+func TestWordWrapBalance(t *testing.T) {
+	tt := []struct {
+		Input            string
+		Expected         string
+		Limit            int
+		Balance          bool
+		MinLastLineWords int
+	}{
+		// Lines are narrowed as long as the line count stays the same:
+		{
+			"The quick brown fox jumps over the lazy dog",
+			"The quick brown\nfox jumps over\nthe lazy dog",
+			20,
+			true,
+			0,
+		},
+		// Every paragraph gets balanced on its own:
+		{
+			"A title that wraps\nThe quick brown fox jumps over the lazy dog",
+			"A title that wraps\nThe quick brown\nfox jumps over\nthe lazy dog",
+			20,
+			true,
+			0,
+		},
+		// Words are pulled down onto the last line:
+		{
+			"The quick brown fox jumps over the lazy dog\n",
+			"The quick brown fox\njumps over the\nlazy dog\n",
+			20,
+			false,
+			2,
+		},
+		// Unless that would take another line:
+		{
+			"foobar baz",
+			"foobar\nbaz",
+			8,
+			false,
+			2,
+		},
+		// ANSI sequences don't affect the width:
+		{
+			"\x1B[31mThe quick brown fox\x1B[0m jumps over the lazy dog",
+			"\x1B[31mThe quick brown\nfox\x1B[0m jumps over\nthe lazy dog",
+			20,
+			true,
+			0,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.Balance = tc.Balance
+		f.MinLastLineWords = tc.MinLastLineWords
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%s`\n\nActual Output:\n\n`%s`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWordWrapPreserveStyles(t *testing.T) {
+	t.Parallel()
+
+	f := NewWriter(4)
+	f.PreserveStyles = true
+	_, _ = f.Write([]byte("foo \x1B[41mbar baz\n\nqux\x1B[0m quux"))
+	_ = f.Close()
+
+	// blank lines aren't styled at all
+	expected := "foo\n\x1B[41mbar\x1B[0m\n\x1B[41mbaz\x1B[0m\n\n\x1B[41mqux\x1B[0m\nquux"
+	if f.String() != expected {
+		t.Errorf("expected:\n\n`%q`\n\nActual Output:\n\n`%q`", expected, f.String())
+	}
+}
+
+func TestWordWrapMaxLines(t *testing.T) {
+	tt := []struct {
+		Input     string
+		Expected  string
+		Truncated bool
+	}{
+		// The tail is put on the last line:
+		{
+			"The quick brown fox jumps over the lazy dog",
+			"The quick\nbrown fox…",
+			true,
+		},
+		// Nothing got dropped:
+		{
+			"foo bar\n",
+			"foo bar\n",
+			false,
+		},
+		{
+			"\x1B[31mThe quick brown\x1B[0m fox",
+			"\x1B[31mThe quick\nbrown\x1B[0m fox",
+			false,
+		},
+		// Whitespace and escape sequences aren't content:
+		{
+			"The quick brown fox\n \x1B[0m",
+			"The quick\nbrown fox\n",
+			false,
+		},
+		// Open sequences get closed:
+		{
+			"The \x1B[41mquick brown fox jumps",
+			"The \x1B[41mquick\nbrown fox…\x1B[0m",
+			true,
+		},
+		// The last line is cut to make room for the tail:
+		{
+			"foo\nbarbazquux\nqux",
+			"foo\nbarbazquu…",
+			true,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(10)
+		f.MaxLines = 2
+		f.Tail = "…"
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, f.String())
+		}
+		if f.Truncated() != tc.Truncated {
+			t.Errorf("Test %d, expected truncated to be %t", i, tc.Truncated)
+		}
+	}
+}
+
+func TestWordWrapMaxLinesUnlimited(t *testing.T) {
+	tt := []struct {
+		Input    string
+		Expected string
+		MaxLines int
+	}{
+		// Without a limit, the last line only gets cut off for the tail:
+		{
+			"first line here\nsecond line\nthird",
+			"first line here…",
+			1,
+		},
+		// The line ending of the last line is kept:
+		{
+			"short\ntext\n",
+			"short\ntext\n",
+			2,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(0)
+		f.MaxLines = tc.MaxLines
+		f.Tail = "…"
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWordWrapBreakFunc(t *testing.T) {
+	mandatory := func(prev, next rune) Break {
+		if prev == ':' {
+			return BreakMandatory
+		}
+		return BreakNone
+	}
+
+	tt := []struct {
+		Input     string
+		Expected  string
+		Limit     int
+		BreakFunc BreakFunc
+	}{
+		// Separators of paths stay on the current line:
+		{
+			"see /usr/local/lib/foo",
+			"see /usr/\nlocal/lib/\nfoo",
+			10,
+			BreakPaths,
+		},
+		{
+			"C:\\Users\\muesli\\go",
+			"C:\\Users\\\nmuesli\\go",
+			9,
+			BreakPaths,
+		},
+		{
+			"abc/def",
+			"abc/\ndef",
+			4,
+			BreakPaths,
+		},
+		// Dots move to the next line:
+		{
+			"call foo.Bar().Baz()",
+			"call foo\n.Bar()\n.Baz()",
+			10,
+			BreakDotted,
+		},
+		{
+			"abc.def",
+			"abc\n.def",
+			4,
+			BreakDotted,
+		},
+		{
+			"a wordWrapWriter here",
+			"a word\nWrap\nWriter\nhere",
+			6,
+			BreakCamelCase,
+		},
+		{
+			"well-known long-term",
+			"well-\nknown\nlong-\nterm",
+			6,
+			BreakProse,
+		},
+		{
+			"a:b:c",
+			"a:\nb:\nc",
+			20,
+			mandatory,
+		},
+		{
+			"foo.barBaz/qux",
+			"foo\n.bar\nBaz/\nqux",
+			4,
+			AnyBreak(BreakDotted, BreakCamelCase, BreakPaths),
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.Breakpoints = nil
+		f.BreakFunc = tc.BreakFunc
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWordWrapTabs(t *testing.T) {
+	tt := []struct {
+		Input    string
+		Expected string
+		KeepTabs bool
+	}{
+		// Tabs expand to the next tab stop, ignoring escape sequences:
+		{
+			"a\tbc\td\te\x1B[1mfg\x1B[0m\th",
+			"a   bc  d\ne\x1B[1mfg\x1B[0m h",
+			false,
+		},
+		// Kept tabs take up the same width:
+		{
+			"a\tbc\td\te\x1B[1mfg\x1B[0m\th",
+			"a\tbc\td\ne\x1B[1mfg\x1B[0m\th",
+			true,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(10)
+		f.TabWidth = 4
+		f.KeepTabs = tc.KeepTabs
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWordWrapTabsLayout(t *testing.T) {
+	tt := []struct {
+		Input     string
+		Expected  string
+		TabWidth  int
+		KeepTabs  bool
+		Balance   bool
+		Alignment Alignment
+	}{
+		// Balancing takes the width of tabs into account:
+		{
+			"a\tb\tc\td\te\tf\tg\th",
+			"a       b\nc       d\ne       f\ng       h",
+			8,
+			false,
+			true,
+			AlignLeft,
+		},
+		{
+			"a\tb\tc\td\te\tf\tg\th",
+			"a\tb\nc\td\ne\tf\ng\th",
+			8,
+			true,
+			true,
+			AlignLeft,
+		},
+		// Kept tabs reach up to their tab stop when justifying:
+		{
+			"aa\tbb cc dd ee",
+			"aa\t  bb\ncc dd ee",
+			8,
+			true,
+			false,
+			AlignJustify,
+		},
+		// Only the gaps following the last tab get widened:
+		{
+			"aa bb\tcc dd ee ff",
+			"aa bb\t  cc\ndd ee ff",
+			4,
+			true,
+			false,
+			AlignJustify,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(12)
+		f.TabWidth = tc.TabWidth
+		f.KeepTabs = tc.KeepTabs
+		f.Balance = tc.Balance
+		f.Alignment = tc.Alignment
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWordWrapRefill(t *testing.T) {
+	tt := []struct {
+		Input    string
+		Expected string
+		Limit    int
+	}{
+		// Single line breaks are joined and the paragraph gets re-wrapped:
+		{
+			"The quick brown\nfox jumps over\nthe lazy dog.",
+			"The quick\nbrown fox\njumps over\nthe lazy\ndog.",
+			10,
+		},
+		// Blank lines separate paragraphs, trailing whitespace is dropped:
+		{
+			"foo  \nbar\n\n\nbaz\nqux\n",
+			"foo bar\n\n\nbaz qux\n",
+			10,
+		},
+		// A change of indentation starts a new paragraph, whose wrapped
+		// lines keep the indentation:
+		{
+			"foo\n  bar baz\n  qux\nquux",
+			"foo\n  bar baz\n  qux\nquux",
+			9,
+		},
+		{
+			"foo\n  bar baz qux",
+			"foo\n  bar\n  baz\n  qux",
+			7,
+		},
+		// Words exceeding the limit don't leave lines holding nothing but
+		// the indentation behind:
+		{
+			"  aaaa bbbbbbbbbbbbbb\n  cc",
+			"  aaaa\n  bbbbbbbbbbbbbb\n  cc",
+			10,
+		},
+		{
+			"  see https://example.com/foo\n  for details",
+			"  see\n  https://example.com/foo\n  for\n  details",
+			10,
+		},
+		// CRLF line endings are joined as well:
+		{
+			"foo\r\nbar\r\n\r\nbaz",
+			"foo bar\r\n\r\nbaz",
+			10,
+		},
+		// ANSI sequences don't affect the width:
+		{
+			"\x1B[31mfoo\nbar\x1B[0m\nbaz",
+			"\x1B[31mfoo bar\x1B[0m\nbaz",
+			7,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.Refill = true
+
+		// write a byte at a time, so lines arrive in pieces
+		for j := 0; j < len(tc.Input); j++ {
+			if _, err := f.Write([]byte{tc.Input[j]}); err != nil {
+				t.Error(err)
+			}
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWordWrapRecordLines(t *testing.T) {
+	t.Parallel()
+
+	f := NewWriter(5)
+	f.RecordLines = true
+	_, _ = f.Write([]byte("foo bar\x1b[1mbaz"))
+	_, _ = f.Write([]byte("\x1b[0m qux\n\nab"))
+	_ = f.Close()
+
+	expected := []position.Line{
+		{SourceStart: 0, SourceEnd: 3, OutputStart: 0, OutputEnd: 3, Width: 3},
+		{SourceStart: 4, SourceEnd: 18, OutputStart: 4, OutputEnd: 18, Width: 6},
+		{SourceStart: 19, SourceEnd: 22, OutputStart: 19, OutputEnd: 22, Width: 3},
+		{SourceStart: 23, SourceEnd: 23, OutputStart: 23, OutputEnd: 23, Width: 0},
+		{SourceStart: 24, SourceEnd: 26, OutputStart: 24, OutputEnd: 26, Width: 2},
+	}
+	if actual := f.Lines(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected:\n\n`%+v`\n\nActual Output:\n\n`%+v`", expected, actual)
+	}
+}
+
+func TestNewWriterPipeChunked(t *testing.T) {
+	in := "日本語 テキスト\r\nfoo ✓ bar"
+	expected := String(in, 4)
+
+	// runes split across writes are not mangled
+	b := &bytes.Buffer{}
+	f := NewWriterPipe(b, 4)
+	for i := 0; i < len(in); i++ {
+		if _, err := f.Write([]byte{in[i]}); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if b.String() != expected {
+		t.Errorf("expected:\n\n`%q`\n\nActual Output:\n\n`%q`", expected, b.String())
+	}
+}
+
+func TestIncompleteRune(t *testing.T) {
+	tt := []struct {
+		Input    string
+		Expected string
+		Limit    int
+	}{
+		// an incomplete rune at the end of the input is passed through as is
+		{"abc\xe4\xb8", "abc\xe4\xb8", 10},
+		{"abcdef\xe4\xb8", "abcdef\xe4\xb8", 4},
+	}
+
+	for i, tc := range tt {
+		actual := String(tc.Input, tc.Limit)
+		if actual != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, actual)
+		}
+	}
+}
+
+func TestNewWriterPipe(t *testing.T) {
+	b := &bytes.Buffer{}
+	f := NewWriterPipe(b, 4)
+
+	if _, err := f.Write([]byte("foo bar")); err != nil {
+		t.Error(err)
+	}
+
+	// completed lines are forwarded right away
+	actual := b.String()
+	expected := "foo\n"
+	if actual != expected {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
+	}
+
+	if _, err := f.Write([]byte(" baz")); err != nil {
+		t.Error(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Error(err)
+	}
+
+	actual = b.String()
+	expected = "foo\nbar\nbaz"
+	if actual != expected {
+		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
+	}
+	if f.String() != "" {
+		t.Errorf("expected empty result, got `%s`", f.String())
+	}
+}
+
+func TestNewWriterPipeBidi(t *testing.T) {
+	b := &bytes.Buffer{}
+	f := NewWriterPipe(b, 3)
+	f.Bidi = true
+
+	if _, err := f.Write([]byte("\x1b[1mאב גד\x1b[0m")); err != nil {
+		t.Error(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Error(err)
+	}
+
+	actual := b.String()
+	expected := "\x1b[1mבא\nדג\x1b[0m"
+	if actual != expected {
+		t.Errorf("expected:\n\n`%q`\n\nActual Output:\n\n`%q`", expected, actual)
+	}
+}
+
+func TestWriter_Error(t *testing.T) {
+	f := NewWriterPipe(fakeWriter{}, 3)
+
+	if _, err := f.Write([]byte("foo bar")); err != fakeErr {
+		t.Error(err)
+	}
+
+	f = NewWriterPipe(fakeWriter{}, 3)
+	if _, err := f.Write([]byte("foo")); err != nil {
+		t.Error(err)
+	}
+	if err := f.Close(); err != fakeErr {
+		t.Error(err)
+	}
+}
+
+var fakeErr = errors.New("fake error")
+
+type fakeWriter struct{}
+
+func (fakeWriter) Write(_ []byte) (int, error) {
+	return 0, fakeErr
+}
+
+func TestWordWrapRecordLinesColumns(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input  string
+		Limit  int
+		Setup  func(f *WordWrap)
+		Offset int
+		Row    int
+		Col    int
+	}{
+		// expanded tabs
+		{
+			"a\tb",
+			10,
+			func(f *WordWrap) { f.TabWidth = 4 },
+			2,
+			0,
+			4,
+		},
+		// kept tabs
+		{
+			"a\tb",
+			10,
+			func(f *WordWrap) { f.TabWidth = 4; f.KeepTabs = true },
+			2,
+			0,
+			4,
+		},
+		// justified gaps
+		{
+			"a bb ccccc",
+			6,
+			func(f *WordWrap) { f.Alignment = AlignJustify },
+			2,
+			0,
+			4,
+		},
+		// continued indentation of refilled paragraphs
+		{
+			"  foo bar\n  baz",
+			9,
+			func(f *WordWrap) { f.Refill = true },
+			12,
+			1,
+			2,
+		},
+		// words following a line of whitespace only
+		{
+			"foo\n   bar",
+			5,
+			func(f *WordWrap) {},
+			8,
+			2,
+			1,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.RecordLines = true
+		tc.Setup(f)
+		_, _ = f.Write([]byte(tc.Input))
+		_ = f.Close()
+
+		src, lines := []byte(tc.Input), f.Lines()
+		if row, col := position.SourceToVisual(src, lines, tc.Offset); row != tc.Row || col != tc.Col {
+			t.Errorf("Test %d, expected:\n\n`%d:%d`\n\nActual Output:\n\n`%d:%d`", i, tc.Row, tc.Col, row, col)
+		}
+		if offset := position.VisualToSource(src, lines, tc.Row, tc.Col); offset != tc.Offset {
+			t.Errorf("Test %d, expected:\n\n`%d`\n\nActual Output:\n\n`%d`", i, tc.Offset, offset)
+		}
+	}
+}
+
+func TestWordWrapRecordLinesOrder(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input string
+		Limit int
+	}{
+		{"foo\n   bar", 5},
+		{"foo   bar baz\n\n    quux", 4},
+		{"a\n \n  \n   bcdef ghi", 3},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.RecordLines = true
+		_, _ = f.Write([]byte(tc.Input))
+		_ = f.Close()
+
+		// lines are recorded in source and output order
+		lines := f.Lines()
+		for j := 1; j < len(lines); j++ {
+			prev, l := lines[j-1], lines[j]
+			if l.SourceStart < prev.SourceEnd || l.SourceEnd < l.SourceStart ||
+				l.OutputStart < prev.OutputEnd {
+				t.Errorf("Test %d, line %d out of order:\n\n`%+v`", i, j, lines)
+			}
+		}
+	}
+}
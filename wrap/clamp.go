@@ -0,0 +1,44 @@
+package wrap
+
+import (
+	"github.com/muesli/reflow/ansi"
+)
+
+// Truncated reports whether any content got dropped, see MaxLines.
+func (w *Wrap) Truncated() bool {
+	return w.clamped.Truncated()
+}
+
+// lastLine reports whether the current line is the last one permitted by
+// MaxLines.
+func (w *Wrap) lastLine() bool {
+	return w.MaxLines > 0 && w.line+1 >= w.MaxLines
+}
+
+// drop discards a rune following the last line permitted by MaxLines. Any
+// visible content clamps that line.
+func (w *Wrap) drop(c rune) {
+	if w.clamped.Drop(c) {
+		w.clamp()
+	}
+}
+
+// clamp drops all content following the current line, which gets Tail put on
+// its end instead.
+func (w *Wrap) clamp() {
+	w.full = true
+	if w.ended {
+		w.buf.Truncate(w.endOff - w.flushed)
+	}
+
+	start := w.lineStart - w.flushed
+	w.clamped.Track(w.buf.Bytes()[:start])
+	line := w.clamped.Line(string(w.buf.Bytes()[start:]), w.limit(), w.Tail)
+
+	w.buf.Truncate(start)
+	_, _ = w.buf.WriteString(line)
+	w.lineLen = ansi.PrintableRuneWidth(line)
+
+	// the line is reset already, see PreserveStyles
+	w.restore = true
+}
@@ -0,0 +1,149 @@
+package wrap
+
+import (
+	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/position"
+	"github.com/muesli/reflow/truncate"
+)
+
+// breakLine breaks the current line for exceeding the limit, putting the
+// markers around the break. Content taking up the room of EndMarker moves to
+// the new line. Styles are reset before the markers and restored after them,
+// so the markers keep their own.
+func (w *Wrap) breakLine() {
+	var tail []byte
+	var anchors []position.Anchor
+	tailLen, tailPos, tailEnd := 0, 0, 0
+	seq := w.style()
+	if start := w.fitOff - w.flushed; w.EndMarker != "" && start < w.buf.Len() {
+		tail = append(tail, w.buf.Bytes()[start:]...)
+		seq = w.fitSeq
+		tailLen, tailPos, tailEnd = w.lineLen-w.fitLen, w.fitPos, w.lineSrcEnd
+		anchors = w.moveAnchors()
+
+		w.buf.Truncate(start)
+		w.lineLen = w.fitLen
+		w.lineSrcEnd = w.fitPos
+
+		if !w.PreserveSpace {
+			var n int
+			tail, n = trimSpace(tail)
+			tailLen -= n
+			anchors = skipCells(anchors, n)
+			tailPos = anchors[0].Source
+			w.nextCol -= n
+		}
+	}
+
+	if w.WidePolicy == truncate.WidePad {
+		// fill the line up to the limit
+		for w.lineLen+ansi.PrintableRuneWidth(w.EndMarker) < w.limit() {
+			w.writeContent(' ')
+			w.lineLen++
+		}
+	}
+
+	if seq != "" {
+		// keep the markers unstyled, which also resets the line, see
+		// PreserveStyles
+		_, _ = w.buf.WriteString("\x1b[0m")
+		w.restore = true
+	}
+	_, _ = w.buf.WriteString(w.EndMarker)
+	w.lineLen += ansi.PrintableRuneWidth(w.EndMarker)
+	w.addNewLine()
+	_, _ = w.buf.WriteString(w.StartMarker)
+	w.prefixLen = ansi.PrintableRuneWidth(w.StartMarker)
+	w.lineLen = w.prefixLen
+	w.forcefulNewline = true
+	if seq != "" {
+		_, _ = w.buf.WriteString(seq)
+		w.restore = false
+	}
+
+	if tail != nil {
+		_, _ = w.buf.Write(tail)
+		w.lineLen += tailLen
+		w.lineSrc = true
+		w.lineSrcStart, w.lineSrcEnd = tailPos, tailEnd
+		for i := range anchors {
+			anchors[i].Col += w.prefixLen
+		}
+		w.anchors = anchors
+		w.nextCol += w.prefixLen - w.fitLen
+	}
+	w.fitOff, w.fitLen, w.fitSeq = w.flushed+w.buf.Len(), w.lineLen, w.style()
+}
+
+// moveAnchors removes the anchors of the content following the end of the
+// line's fitting content, returning them relative to its column.
+func (w *Wrap) moveAnchors() []position.Anchor {
+	anchors := []position.Anchor{{Source: w.fitPos}}
+	i := len(w.anchors)
+	for i > 0 && w.anchors[i-1].Source >= w.fitPos {
+		i--
+	}
+	for _, a := range w.anchors[i:] {
+		if a.Source > w.fitPos {
+			anchors = append(anchors, position.Anchor{Source: a.Source, Col: a.Col - w.fitLen})
+		}
+	}
+	w.anchors = w.anchors[:i]
+	return anchors
+}
+
+// fit remembers the end of the current line's content, as long as it leaves
+// room for EndMarker. The rune just written took up size bytes of the source.
+func (w *Wrap) fit(size int) {
+	if w.EndMarker == "" {
+		return
+	}
+
+	if w.lineLen+ansi.PrintableRuneWidth(w.EndMarker) <= w.limit() {
+		w.fitOff = w.flushed + w.buf.Len()
+		w.fitLen = w.lineLen
+		w.fitPos = w.pos + size
+		w.fitSeq = w.style()
+	}
+}
+
+// trimSpace drops the leading spaces of b, keeping any escape sequences among
+// them. It returns the number of spaces dropped.
+func trimSpace(b []byte) ([]byte, int) {
+	var out []byte
+	var esc bool
+	for i, c := range b {
+		switch {
+		case c == ansi.Marker:
+			esc = true
+		case esc:
+			if ansi.IsTerminator(rune(c)) {
+				esc = false
+			}
+		case c != ' ':
+			return append(out, b[i:]...), i - len(out)
+		default:
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, len(b) - len(out)
+}
+
+// skipCells drops the first n cells from anchors, which start at column 0.
+// Cells not following an anchor take up a byte of the source each.
+func skipCells(anchors []position.Anchor, n int) []position.Anchor {
+	if n == 0 {
+		return anchors
+	}
+
+	i := 0
+	for i+1 < len(anchors) && anchors[i+1].Col <= n {
+		i++
+	}
+	skipped := []position.Anchor{{Source: anchors[i].Source + n - anchors[i].Col}}
+	for _, a := range anchors[i+1:] {
+		skipped = append(skipped, position.Anchor{Source: a.Source, Col: a.Col - n})
+	}
+	return skipped
+}
@@ -2,11 +2,38 @@ package wrap
 
 import (
 	"bytes"
+	"io"
+	"math"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/mattn/go-runewidth"
 	"github.com/muesli/reflow/ansi"
+	"github.com/muesli/reflow/internal/wrapping"
+	"github.com/muesli/reflow/position"
+	"github.com/muesli/reflow/truncate"
+)
+
+// LineEnding describes the line break sequence written to the output.
+type LineEnding int
+
+// Available line endings.
+const (
+	// LineEndingDefault terminates lines with "\n", leaving any CR of the
+	// input as it is.
+	LineEndingDefault LineEnding = iota
+	// LineEndingLF terminates lines with "\n", converting CRLF line endings
+	// of the input.
+	LineEndingLF
+	// LineEndingCRLF terminates lines with "\r\n".
+	LineEndingCRLF
+	// LineEndingCR terminates lines with "\r".
+	LineEndingCR
+	// LineEndingPreserve keeps the line endings found in the input. Line
+	// breaks added by wrapping use the line ending of the input line they
+	// break, as far as it's part of the same Write.
+	LineEndingPreserve
 )
 
 var (
@@ -20,11 +47,81 @@ type Wrap struct {
 	KeepNewlines  bool
 	PreserveSpace bool
 	TabWidth      int
+	LineEnding    LineEnding
+
+	// EndMarker and StartMarker are put at the end and start of lines broken
+	// for exceeding the limit, e.g. "↩" and "↪ ", so they can be told apart
+	// from line breaks of the input. Both count towards the limit and may
+	// be styled with escape sequences. The styles of the content get reset
+	// in front of the markers and restored after them.
+	EndMarker   string
+	StartMarker string
+
+	// WidePolicy decides about double-width runes only a single cell is
+	// left for. Padding fills every line broken short with spaces.
+	WidePolicy truncate.WidePolicy
+
+	// KeepTabs writes tabs as they are, rather than expanding them to
+	// spaces. They still take up the width up to the next tab stop.
+	KeepTabs bool
+
+	// LimitFunc, if set, returns the limit of every line by its index,
+	// counting from zero, in place of Limit. Lines with a non-positive
+	// limit don't get wrapped.
+	LimitFunc func(line int) int
+
+	// MaxLines stops wrapping after this many lines, dropping any following
+	// content. Tail is put on the last line if content got dropped, see
+	// Truncated.
+	MaxLines int
+	Tail     string
+
+	// PreserveStyles resets all styles at the end of every line and restores
+	// them at the start of the next, so that each line is self-contained and
+	// backgrounds don't bleed into line breaks.
+	PreserveStyles bool
+
+	// RecordLines keeps a table of all output lines and the source text
+	// they were made of, see Lines.
+	RecordLines bool
 
 	buf             *bytes.Buffer
+	runes           wrapping.Runes
+	forward         io.Writer
+	ansiWriter      *ansi.Writer
+	line            int
 	lineLen         int
+	prefixLen       int
+	endings         wrapping.Endings
+	cr              bool
 	ansi            bool
+	restore         bool
+	full            bool
+	ended           bool
+	endOff          int
+	closed          bool
+	clamped         wrapping.Clamp
 	forcefulNewline bool
+
+	// the end of the line's content leaving room for EndMarker, by output
+	// offset, width, source offset and the styles active there
+	fitOff int
+	fitLen int
+	fitPos int
+	fitSeq string
+
+	// source offsets, see RecordLines
+	lines        []position.Line
+	srcPos       int
+	pos          int
+	flushed      int
+	lineStart    int
+	lineSrc      bool
+	lineSrcStart int
+	lineSrcEnd   int
+	anchors      []position.Anchor
+	nextSrc      int
+	nextCol      int
 }
 
 // NewWriter returns a new instance of a wrapping writer, initialized with
@@ -45,18 +142,117 @@ func NewWriter(limit int) *Wrap {
 	}
 }
 
+// NewWriterPipe returns a new instance of a wrapping writer, initialized with
+// default settings. Output is written to forward as soon as it can't change
+// anymore, rather than accumulating the entire result. Call Close to write
+// any remaining output.
+func NewWriterPipe(forward io.Writer, limit int) *Wrap {
+	w := NewWriter(limit)
+	w.forward = forward
+	return w
+}
+
 // Bytes is shorthand for declaring a new default Wrap instance,
 // used to immediately wrap a byte slice.
 func Bytes(b []byte, limit int) []byte {
 	f := NewWriter(limit)
 	_, _ = f.Write(b)
+	_ = f.Close()
 
 	return f.Bytes()
 }
 
 func (w *Wrap) addNewLine() {
-	_, _ = w.buf.WriteRune('\n')
+	if w.lastLine() {
+		// any following content gets dropped, see MaxLines, while the
+		// line ending only is if any does
+		w.full = true
+		w.ended = true
+		w.endOff = w.flushed + w.buf.Len()
+		w.resetStyles()
+		_, _ = w.buf.WriteString(w.newline())
+		return
+	}
+
+	w.resetStyles()
+	w.addLine()
+	_, _ = w.buf.WriteString(w.newline())
+	w.line++
 	w.lineLen = 0
+	w.prefixLen = 0
+	w.lineStart = w.flushed + w.buf.Len()
+	w.fitOff, w.fitLen, w.fitPos = w.lineStart, 0, w.pos
+	w.fitSeq = w.style()
+}
+
+// newline returns the line ending to be written for the next line break.
+func (w *Wrap) newline() string {
+	switch w.LineEnding {
+	case LineEndingCRLF:
+		return "\r\n"
+	case LineEndingCR:
+		return "\r"
+	case LineEndingPreserve:
+		if e := w.endings.Preserved(); e != "" {
+			return e
+		}
+	}
+	return "\n"
+}
+
+// crlf reports whether CRLF line endings of the input are recognised as
+// such, see LineEndingDefault.
+func (w *Wrap) crlf() bool {
+	return w.LineEnding != LineEndingDefault
+}
+
+// addLine records the current line in the line table.
+func (w *Wrap) addLine() {
+	if !w.RecordLines {
+		return
+	}
+	if !w.lineSrc {
+		w.lineSrcStart = w.pos
+		w.lineSrcEnd = w.pos
+	}
+
+	anchors := w.anchors
+	if len(anchors) == 1 && anchors[0] == (position.Anchor{Source: w.lineSrcStart}) {
+		// the line is displayed as is
+		anchors = nil
+	}
+
+	w.lines = append(w.lines, position.Line{
+		SourceStart: w.lineSrcStart,
+		SourceEnd:   w.lineSrcEnd,
+		OutputStart: w.lineStart,
+		OutputEnd:   w.flushed + w.buf.Len(),
+		Width:       w.lineLen,
+		Anchors:     anchors,
+	})
+	w.lineSrc = false
+	w.anchors = nil
+}
+
+// Lines returns the table of output lines recorded so far. Lines are only
+// recorded if RecordLines is enabled and Limit is positive or LimitFunc is
+// set. Call Close to record the final line.
+func (w *Wrap) Lines() []position.Line {
+	return w.lines
+}
+
+// limit returns the limit of the current line.
+func (w *Wrap) limit() int {
+	if w.LimitFunc == nil {
+		if w.Limit > 0 {
+			return w.Limit
+		}
+		return math.MaxInt32
+	}
+	if l := w.LimitFunc(w.line); l > 0 {
+		return l
+	}
+	return math.MaxInt32
 }
 
 // String is shorthand for declaring a new default Wrap instance,
@@ -66,55 +262,306 @@ func String(s string, limit int) string {
 }
 
 func (w *Wrap) Write(b []byte) (int, error) {
-	s := strings.Replace(string(b), "\t", strings.Repeat(" ", w.TabWidth), -1)
+	n := len(b)
+	if _, err := w.write(w.runes.Complete(b)); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// write wraps b, which only holds complete runes.
+func (w *Wrap) write(b []byte) (int, error) {
+	s := string(b)
 	if !w.KeepNewlines {
+		if w.crlf() {
+			s = strings.Replace(s, "\r\n", "", -1)
+		}
 		s = strings.Replace(s, "\n", "", -1)
 	}
 
 	width := ansi.PrintableRuneWidth(s)
+	hasNewline := strings.IndexFunc(s, func(c rune) bool {
+		return c == '\r' || inGroup(w.Newline, c)
+	}) >= 0
 
-	if w.Limit <= 0 || w.lineLen+width <= w.Limit {
+	if (w.Limit <= 0 && w.LimitFunc == nil && w.MaxLines == 0) ||
+		(w.lineLen+width <= w.limit() && !hasNewline && !strings.ContainsRune(s, '\t') &&
+			w.EndMarker == "" && w.StartMarker == "" && !w.RecordLines && !w.PreserveStyles && w.MaxLines == 0) {
 		w.lineLen += width
-		return w.buf.Write(b)
+		w.srcPos += len(b)
+		_, _ = w.buf.Write(b)
+		return w.flush(len(b))
 	}
 
-	for _, c := range s {
-		if c == ansi.Marker {
-			w.ansi = true
-		} else if w.ansi {
-			if ansi.IsTerminator(c) {
-				w.ansi = false
-			}
-		} else if inGroup(w.Newline, c) {
-			w.addNewLine()
-			w.forcefulNewline = false
+	s = string(b)
+	for i, c := range s {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		w.pos = w.srcPos + i
+		// look for the line ending of the current input line, which the
+		// LF of a CRLF line ending doesn't belong to anymore
+		if w.LineEnding == LineEndingPreserve && (c != '\n' || !w.cr) {
+			w.endings.LookAhead(s[i:], i == 0, w.Newline)
+		}
+
+		if w.full {
+			w.drop(c)
 			continue
-		} else {
-			width := runewidth.RuneWidth(c)
+		}
+		if !w.KeepNewlines && (c == '\n' || (c == '\r' && strings.HasPrefix(s[i+1:], "\n") && w.crlf())) {
+			continue
+		}
+		if c == '\t' && !w.ansi {
+			w.writeTab(size)
+			continue
+		}
+		w.writeRune(c, size)
+	}
+
+	w.srcPos += len(b)
+	return w.flush(len(b))
+}
+
+// writeRune wraps a single rune, taking up size bytes of the source.
+func (w *Wrap) writeRune(c rune, size int) {
+	cr := w.cr
+	w.cr = c == '\r' && w.crlf()
 
-			if w.lineLen+width > w.Limit {
-				w.addNewLine()
-				w.forcefulNewline = true
+	if c == ansi.Marker {
+		w.ansi = true
+	} else if w.ansi {
+		if ansi.IsTerminator(c) {
+			w.ansi = false
+		}
+	} else if inGroup(w.Newline, c) {
+		if c == '\n' && cr {
+			if inGroup(w.Newline, '\r') {
+				// a CRLF line ending already broke the line at its CR
+				if w.LineEnding == LineEndingPreserve {
+					_, _ = w.buf.WriteRune(c)
+					w.endings.Last = "\r\n"
+					w.lineStart++
+					w.fitOff++
+				}
+				return
 			}
 
-			if w.lineLen == 0 {
-				if w.forcefulNewline && !w.PreserveSpace && unicode.IsSpace(c) {
-					continue
+			// the CR of a CRLF line ending got written as is
+			if bytes.HasSuffix(w.buf.Bytes(), []byte{'\r'}) {
+				w.buf.Truncate(w.buf.Len() - 1)
+				if w.lineSrcEnd == w.pos {
+					w.lineSrcEnd--
 				}
-			} else {
-				w.forcefulNewline = false
 			}
+			w.endings.Last = "\r\n"
+		} else {
+			w.endings.Last = string(c)
+		}
+
+		w.endings.EndLine()
+		w.addNewLine()
+		w.forcefulNewline = false
+		return
+	} else {
+		width := runewidth.RuneWidth(c)
+		overflow := w.WidePolicy == truncate.WideOverflow && width > 1 && w.lineLen < w.limit()
+
+		if w.lineLen+width > w.limit() && !overflow {
+			if w.lastLine() {
+				w.full = true
+				w.drop(c)
+				return
+			}
+			w.breakLine()
+		}
 
-			w.lineLen += width
+		if w.lineLen == w.prefixLen {
+			if w.forcefulNewline && !w.PreserveSpace && unicode.IsSpace(c) {
+				return
+			}
+		} else {
+			w.forcefulNewline = false
 		}
 
+		w.addSource(size, width)
+		w.lineLen += width
+		w.writeContent(c)
+		w.fit(size)
+		return
+	}
+
+	w.addSource(size, 0)
+	w.writeContent(c)
+}
+
+// writeTab expands a tab to the next tab stop, or writes it as is with
+// KeepTabs enabled. Tabs that don't fit the current line move to the next
+// as a whole.
+func (w *Wrap) writeTab(size int) {
+	w.cr = false
+	if w.TabWidth <= 0 {
+		return
+	}
+
+	n := w.TabWidth - w.lineLen%w.TabWidth
+	if w.lineLen+n > w.limit() && w.lineLen > w.prefixLen {
+		if w.lastLine() {
+			w.full = true
+			return
+		}
+		w.breakLine()
+		n = w.TabWidth - w.lineLen%w.TabWidth
+	}
+	if room := w.limit() - w.lineLen; n > room && room > 0 {
+		// tabs wider than an empty line get cut short
+		n = room
+	}
+
+	if w.lineLen == w.prefixLen {
+		if w.forcefulNewline && !w.PreserveSpace {
+			return
+		}
+	} else {
+		w.forcefulNewline = false
+	}
+
+	// a tab takes up no cells as far as the line table is concerned, so
+	// the rune following it gets anchored to its column
+	w.addSource(size, 0)
+	w.lineLen += n
+	if w.KeepTabs {
+		w.writeContent('\t')
+	} else {
+		for i := 0; i < n; i++ {
+			w.writeContent(' ')
+		}
+	}
+	w.fit(size)
+}
+
+// addSource extends the current line by the rune at pos, taking up size bytes
+// of the source and width cells at the current column. Runes not following
+// the previous one of the line in both source and output get anchored to
+// their column.
+func (w *Wrap) addSource(size, width int) {
+	if !w.lineSrc {
+		w.lineSrc = true
+		w.lineSrcStart = w.pos
+	}
+	w.lineSrcEnd = w.pos + size
+
+	if !w.RecordLines {
+		return
+	}
+	if len(w.anchors) == 0 || w.pos != w.nextSrc || w.lineLen != w.nextCol {
+		w.anchors = append(w.anchors, position.Anchor{Source: w.pos, Col: w.lineLen})
+	}
+	w.nextSrc, w.nextCol = w.pos+size, w.lineLen+width
+}
+
+// writeContent writes a rune of the current line to the buffer. With
+// PreserveStyles enabled, the styles active at the end of the previous line
+// get restored first. Styles are tracked for markers as well, so they can be
+// kept off them.
+func (w *Wrap) writeContent(c rune) {
+	if !w.PreserveStyles && w.EndMarker == "" && w.StartMarker == "" {
 		_, _ = w.buf.WriteRune(c)
+		return
+	}
+
+	if w.ansiWriter == nil {
+		w.ansiWriter = &ansi.Writer{Forward: w.buf}
+	}
+	if w.restore {
+		w.ansiWriter.RestoreAnsi()
+		w.restore = false
+	}
+	_, _ = w.ansiWriter.Write([]byte(string(c)))
+}
+
+// style returns the styles active at the end of the content written so far,
+// as far as they're tracked, see writeContent.
+func (w *Wrap) style() string {
+	if w.ansiWriter == nil {
+		return ""
+	}
+	return w.ansiWriter.LastSequence()
+}
+
+// resetStyles resets all styles at the end of a line, so they can't bleed
+// into the following line, see PreserveStyles.
+func (w *Wrap) resetStyles() {
+	if w.ansiWriter == nil || !w.PreserveStyles {
+		return
+	}
+
+	// lines without any content don't get restored, so they don't need to
+	// be reset either
+	if !w.restore {
+		w.ansiWriter.ResetAnsi()
 	}
+	w.restore = true
+}
+
+// flush writes all output to the forward writer, if there is one. A trailing
+// CR is held back, as it may turn out to be part of a CRLF line ending. It
+// returns n, or 0 along with any error of the forward writer.
+func (w *Wrap) flush(n int) (int, error) {
+	if w.forward == nil {
+		return n, nil
+	}
+
+	l := w.buf.Len()
+	if w.cr && bytes.HasSuffix(w.buf.Bytes(), []byte{'\r'}) {
+		l--
+	}
+	if w.MaxLines > 0 && !w.closed && w.lineStart-w.flushed < l {
+		// the current line may still get clamped
+		l = w.lineStart - w.flushed
+	} else if w.EndMarker != "" && !w.closed && w.fitOff-w.flushed < l {
+		// content beyond the room left for the end marker may still move
+		// to the next line
+		l = w.fitOff - w.flushed
+	}
+	b := w.buf.Next(l)
+	w.flushed += l
+	if w.MaxLines > 0 {
+		w.clamped.Track(b)
+	}
+	if _, err := w.forward.Write(b); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Close will finish the wrap operation, writing any remaining output to the
+// forward writer of writers created by NewWriterPipe.
+func (w *Wrap) Close() error {
+	if b := w.runes.Rest(); len(b) > 0 {
+		// an incomplete rune at the very end of the input, which gets
+		// passed through unchanged
+		w.pos = w.srcPos
+		if w.full {
+			w.drop(utf8.RuneError)
+		} else {
+			w.addSource(len(b), 0)
+			_, _ = w.buf.Write(b)
+		}
+		w.srcPos += len(b)
+	}
+
+	w.closed = true
+	w.resetStyles()
+	w.pos = w.srcPos
+	w.addLine()
 
-	return len(b), nil
+	w.cr = false
+	_, err := w.flush(0)
+	return err
 }
 
-// Bytes returns the wrapped result as a byte slice.
+// Bytes returns the wrapped result as a byte slice. Writers created by
+// NewWriterPipe return an empty result, as all output went to the forward
+// writer.
 func (w *Wrap) Bytes() []byte {
 	return w.buf.Bytes()
 }
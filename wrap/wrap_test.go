@@ -2,7 +2,12 @@ package wrap
 
 import (
 	"bytes"
+	"errors"
+	"reflect"
 	"testing"
+
+	"github.com/muesli/reflow/position"
+	"github.com/muesli/reflow/truncate"
 )
 
 func TestWrap(t *testing.T) {
@@ -77,19 +82,20 @@ func TestWrap(t *testing.T) {
 			PreserveSpace: false,
 			TabWidth:      0,
 		},
-		// Tabs are broken up according to the configured TabWidth
+		// Tabs expand to the next tab stop, moving to the next line as a
+		// whole if they don't fit
 		{
 			Input:         "foo\tbar",
-			Expected:      "foo \n  ba\nr",
+			Expected:      "foo\n   b\nar",
 			Limit:         4,
 			KeepNewlines:  true,
 			PreserveSpace: true,
 			TabWidth:      3,
 		},
-		// Remaining width of wrapped tab is ignored when space is not preserved
+		// Wrapped tabs are dropped when space is not preserved
 		{
 			Input:         "foo\tbar",
-			Expected:      "foo \nbar",
+			Expected:      "foo\nbar",
 			Limit:         4,
 			KeepNewlines:  true,
 			PreserveSpace: false,
@@ -132,6 +138,109 @@ func TestWrap(t *testing.T) {
 	}
 }
 
+func TestWrapLineEnding(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input      string
+		Expected   string
+		Limit      int
+		Newline    []rune
+		LineEnding LineEnding
+	}{
+		// CRs of the input are left as they are by default:
+		{
+			Input:      "foobar\r\nbaz",
+			Expected:   "foo\nbar\r\nbaz",
+			Limit:      3,
+			Newline:    []rune{'\n'},
+			LineEnding: LineEndingDefault,
+		},
+		// CRLF line endings are converted:
+		{
+			Input:      "foobar\r\nbaz",
+			Expected:   "foo\nbar\nbaz",
+			Limit:      3,
+			Newline:    []rune{'\n'},
+			LineEnding: LineEndingLF,
+		},
+		{
+			Input:      "foobar\nbaz",
+			Expected:   "foo\r\nbar\r\nbaz",
+			Limit:      3,
+			Newline:    []rune{'\n'},
+			LineEnding: LineEndingCRLF,
+		},
+		{
+			Input:      "foobar\nbaz",
+			Expected:   "foo\rbar\rbaz",
+			Limit:      3,
+			Newline:    []rune{'\n'},
+			LineEnding: LineEndingCR,
+		},
+		// Line endings of the input are preserved and used for line breaks:
+		{
+			Input:      "foo\r\nbarbaz",
+			Expected:   "foo\r\nbar\r\nbaz",
+			Limit:      3,
+			Newline:    []rune{'\n'},
+			LineEnding: LineEndingPreserve,
+		},
+		// Line breaks use the line ending of the line they break:
+		{
+			Input:      "foobar\r\nbaz",
+			Expected:   "foo\r\nbar\r\nbaz",
+			Limit:      3,
+			Newline:    []rune{'\n'},
+			LineEnding: LineEndingPreserve,
+		},
+		{
+			Input:      "foobar\nbazqux\r\n",
+			Expected:   "foo\nbar\nbaz\r\nqux\r\n",
+			Limit:      3,
+			Newline:    []rune{'\n'},
+			LineEnding: LineEndingPreserve,
+		},
+		{
+			Input:      "foobar\rbazqux\r\n",
+			Expected:   "foo\rbar\rbaz\r\nqux\r\n",
+			Limit:      3,
+			Newline:    []rune{'\r', '\n'},
+			LineEnding: LineEndingPreserve,
+		},
+		// CRLF is a single line break, even if CR is a newline itself:
+		{
+			Input:      "foo\r\nbar\rbaz",
+			Expected:   "foo\nbar\nbaz",
+			Limit:      3,
+			Newline:    []rune{'\r', '\n'},
+			LineEnding: LineEndingLF,
+		},
+		{
+			Input:      "foo\r\nbar\rbaz",
+			Expected:   "foo\r\nbar\rbaz",
+			Limit:      3,
+			Newline:    []rune{'\r', '\n'},
+			LineEnding: LineEndingPreserve,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.Newline = tc.Newline
+		f.LineEnding = tc.LineEnding
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, f.String())
+		}
+	}
+}
+
 func TestWrapString(t *testing.T) {
 	t.Parallel()
 
@@ -151,3 +260,476 @@ func TestWrapBytes(t *testing.T) {
 		t.Errorf("expected:\n\n`%s`\n\nActual Output:\n\n`%s`", expected, actual)
 	}
 }
+
+func TestWrapLimitFunc(t *testing.T) {
+	t.Parallel()
+
+	f := NewWriter(0)
+	f.LimitFunc = func(line int) int {
+		switch {
+		case line < 2:
+			return 6
+		case line == 4:
+			// no limit at all
+			return 0
+		}
+		return 12
+	}
+	_, _ = f.Write([]byte("abcdefghijklmnopqrstuvwxyz\nABCDEFGHIJKLMNOPQRSTUVWXYZ\n\x1B[1mabcdefghijklmnop"))
+
+	expected := "abcdef\nghijkl\nmnopqrstuvwx\nyz\nABCDEFGHIJKLMNOPQRSTUVWXYZ\n\x1B[1mabcdefghijkl\nmnop"
+	if f.String() != expected {
+		t.Errorf("expected:\n\n`%q`\n\nActual Output:\n\n`%q`", expected, f.String())
+	}
+}
+
+func TestWrapPreserveStyles(t *testing.T) {
+	t.Parallel()
+
+	f := NewWriter(3)
+	f.PreserveStyles = true
+	_, _ = f.Write([]byte("\x1B[1m\x1B[41mfoobar\n\nbaz\x1B[0mqux"))
+	_ = f.Close()
+
+	expected := "\x1B[1m\x1B[41mfoo\x1B[0m\n\x1B[1m\x1B[41mbar\x1B[0m\n\n\x1B[1m\x1B[41mbaz\x1B[0m\nqux"
+	if f.String() != expected {
+		t.Errorf("expected:\n\n`%q`\n\nActual Output:\n\n`%q`", expected, f.String())
+	}
+}
+
+func TestWrapMaxLines(t *testing.T) {
+	tt := []struct {
+		Input     string
+		Expected  string
+		Truncated bool
+	}{
+		{
+			"abcdefghijklmnopqrstuvwxyz",
+			"abcde\nfghi…",
+			true,
+		},
+		{
+			"abcdefghij\n",
+			"abcde\nfghij\n",
+			false,
+		},
+		{
+			"abc def ghij",
+			"abc d\nef g…",
+			true,
+		},
+		{
+			"\x1B[41mabcdefghijklmn",
+			"\x1B[41mabcde\nfghi…\x1B[0m",
+			true,
+		},
+		{
+			"ab\ncd\nef",
+			"ab\ncd…",
+			true,
+		},
+	}
+
+	for i, tc := range tt {
+		var b bytes.Buffer
+		f := NewWriterPipe(&b, 5)
+		f.MaxLines = 2
+		f.Tail = "…"
+
+		// write a byte at a time, the last line must not be flushed early
+		for j := 0; j < len(tc.Input); j++ {
+			if _, err := f.Write([]byte{tc.Input[j]}); err != nil {
+				t.Error(err)
+			}
+		}
+		f.Close()
+
+		if b.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, b.String())
+		}
+		if f.Truncated() != tc.Truncated {
+			t.Errorf("Test %d, expected truncated to be %t", i, tc.Truncated)
+		}
+	}
+}
+
+func TestWrapMaxLinesUnlimited(t *testing.T) {
+	tt := []struct {
+		Input    string
+		Expected string
+		MaxLines int
+	}{
+		// Without a limit, the last line only gets cut off for the tail:
+		{
+			"first line here\nsecond line\nthird",
+			"first line here…",
+			1,
+		},
+		// The line ending of the last line is kept:
+		{
+			"short\ntext\n",
+			"short\ntext\n",
+			2,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(0)
+		f.MaxLines = tc.MaxLines
+		f.Tail = "…"
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+		f.Close()
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWrapTabs(t *testing.T) {
+	tt := []struct {
+		Input         string
+		Expected      string
+		Limit         int
+		KeepTabs      bool
+		PreserveSpace bool
+	}{
+		// Tabs expand to the next tab stop, ignoring escape sequences:
+		{
+			"a\tbc\td\t\x1B[1mefg\x1B[0m\th",
+			"a   bc  d\n\x1B[1mefg\x1B[0m h",
+			10,
+			false,
+			false,
+		},
+		// Kept tabs take up the same width:
+		{
+			"a\tbc\td\t\x1B[1mefg\x1B[0m\th",
+			"a\tbc\td\n\x1B[1mefg\x1B[0m\th",
+			10,
+			true,
+			false,
+		},
+		// Tabs wider than the limit are cut short instead of breaking an
+		// empty line:
+		{
+			"\tfoo",
+			"   \nfoo",
+			3,
+			false,
+			false,
+		},
+		{
+			"\tfoo",
+			"   \nfoo",
+			3,
+			false,
+			true,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.KeepTabs = tc.KeepTabs
+		f.PreserveSpace = tc.PreserveSpace
+
+		_, err := f.Write([]byte(tc.Input))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWrapMarkers(t *testing.T) {
+	tt := []struct {
+		Input    string
+		Expected string
+	}{
+		// Lines that fit don't need any room for the markers:
+		{
+			"abcdefghij",
+			"abcdefghij",
+		},
+		{
+			"abcdefghijk",
+			"abcdefghi\x1B[2m↩\x1B[0m\n↪ jk",
+		},
+		// Line breaks of the input get no markers:
+		{
+			"abcdefgh\x1B[31mijklmnopqrstuvw\x1B[0m\nshort",
+			"abcdefgh\x1B[31mi\x1B[0m\x1B[2m↩\x1B[0m\n↪ \x1B[31mjklmnop\x1B[0m\x1B[2m↩\x1B[0m\n↪ \x1B[31mqrstuvw\x1B[0m\nshort",
+		},
+		// Styles are kept off the markers, and the markers' styles off the
+		// content:
+		{
+			"\x1B[31mabcdefghijkl",
+			"\x1B[31mabcdefghi\x1B[0m\x1B[2m↩\x1B[0m\n↪ \x1B[31mjkl",
+		},
+		{
+			"\x1B[41mabcdefghijkl\x1B[0m",
+			"\x1B[41mabcdefghi\x1B[0m\x1B[2m↩\x1B[0m\n↪ \x1B[41mjkl\x1B[0m",
+		},
+		{
+			"foo bar baz qux",
+			"foo bar b\x1B[2m↩\x1B[0m\n↪ az qux",
+		},
+		// Whitespace moving to the next line is dropped:
+		{
+			"this is a rather long log line",
+			"this is a\x1B[2m↩\x1B[0m\n↪ rather \x1B[2m↩\x1B[0m\n↪ long lo\x1B[2m↩\x1B[0m\n↪ g line",
+		},
+		// CRLF line endings split across writes are still converted:
+		{
+			"abc\r\ndef",
+			"abc\ndef",
+		},
+	}
+
+	for i, tc := range tt {
+		var b bytes.Buffer
+		f := NewWriterPipe(&b, 10)
+		f.EndMarker = "\x1B[2m↩\x1B[0m"
+		f.StartMarker = "↪ "
+		f.LineEnding = LineEndingLF
+
+		// write a byte at a time, so content gets moved after being written
+		for j := 0; j < len(tc.Input); j++ {
+			if _, err := f.Write([]byte{tc.Input[j]}); err != nil {
+				t.Error(err)
+			}
+		}
+		f.Close()
+
+		if b.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, b.String())
+		}
+	}
+}
+
+func TestWrapWidePolicy(t *testing.T) {
+	tt := []struct {
+		Policy   truncate.WidePolicy
+		Expected string
+	}{
+		{
+			truncate.WideDrop,
+			"ab你\n好世\n界",
+		},
+		{
+			truncate.WidePad,
+			"ab你 \n好世 \n界",
+		},
+		{
+			truncate.WideOverflow,
+			"ab你好\n世界",
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(5)
+		f.WidePolicy = tc.Policy
+
+		_, err := f.Write([]byte("ab你好世界"))
+		if err != nil {
+			t.Error(err)
+		}
+
+		if f.String() != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, f.String())
+		}
+	}
+}
+
+func TestWrapRecordLines(t *testing.T) {
+	t.Parallel()
+
+	f := NewWriter(4)
+	f.RecordLines = true
+	_, _ = f.Write([]byte("foobar\nb\tz"))
+	_ = f.Close()
+
+	expected := []position.Line{
+		{SourceStart: 0, SourceEnd: 4, OutputStart: 0, OutputEnd: 4, Width: 4},
+		{SourceStart: 4, SourceEnd: 6, OutputStart: 5, OutputEnd: 7, Width: 2},
+		// the tab expands into spaces, the one wrapping over gets dropped
+		{SourceStart: 7, SourceEnd: 9, OutputStart: 8, OutputEnd: 12, Width: 4},
+		{SourceStart: 9, SourceEnd: 10, OutputStart: 13, OutputEnd: 14, Width: 1},
+	}
+	if actual := f.Lines(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected:\n\n`%+v`\n\nActual Output:\n\n`%+v`", expected, actual)
+	}
+}
+
+func TestNewWriterPipeChunked(t *testing.T) {
+	t.Parallel()
+
+	in := "日本語テキスト\r\nfoo✓bar"
+	expected := String(in, 4)
+
+	// runes split across writes are not mangled
+	b := &bytes.Buffer{}
+	f := NewWriterPipe(b, 4)
+	for i := 0; i < len(in); i++ {
+		if _, err := f.Write([]byte{in[i]}); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if b.String() != expected {
+		t.Errorf("expected:\n\n`%q`\n\nActual Output:\n\n`%q`", expected, b.String())
+	}
+}
+
+func TestIncompleteRune(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input    string
+		Expected string
+		Limit    int
+	}{
+		// an incomplete rune at the end of the input is passed through as is
+		{"abc\xe4\xb8", "abc\xe4\xb8", 10},
+		{"abcdef\xe4\xb8", "abcd\nef\xe4\xb8", 4},
+	}
+
+	for i, tc := range tt {
+		actual := String(tc.Input, tc.Limit)
+		if actual != tc.Expected {
+			t.Errorf("Test %d, expected:\n\n`%q`\n\nActual Output:\n\n`%q`", i, tc.Expected, actual)
+		}
+	}
+}
+
+func TestNewWriterPipe(t *testing.T) {
+	t.Parallel()
+
+	b := &bytes.Buffer{}
+	f := NewWriterPipe(b, 3)
+	f.LineEnding = LineEndingLF
+
+	if _, err := f.Write([]byte("foobar\r")); err != nil {
+		t.Error(err)
+	}
+
+	// a trailing CR is held back until we know what follows it
+	actual := b.String()
+	expected := "foo\nbar"
+	if actual != expected {
+		t.Errorf("expected:\n\n`%q`\n\nActual Output:\n\n`%q`", expected, actual)
+	}
+
+	if _, err := f.Write([]byte("\nbaz")); err != nil {
+		t.Error(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Error(err)
+	}
+
+	actual = b.String()
+	expected = "foo\nbar\nbaz"
+	if actual != expected {
+		t.Errorf("expected:\n\n`%q`\n\nActual Output:\n\n`%q`", expected, actual)
+	}
+	if f.String() != "" {
+		t.Errorf("expected empty result, got `%s`", f.String())
+	}
+}
+
+func TestWriter_Error(t *testing.T) {
+	t.Parallel()
+
+	f := NewWriterPipe(fakeWriter{}, 3)
+
+	if _, err := f.Write([]byte("foo")); err != fakeErr {
+		t.Error(err)
+	}
+	if _, err := f.Write([]byte("foobar")); err != fakeErr {
+		t.Error(err)
+	}
+}
+
+var fakeErr = errors.New("fake error")
+
+type fakeWriter struct{}
+
+func (fakeWriter) Write(_ []byte) (int, error) {
+	return 0, fakeErr
+}
+
+func TestWrapRecordLinesColumns(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		Input  string
+		Limit  int
+		Setup  func(f *Wrap)
+		Offset int
+		Row    int
+		Col    int
+	}{
+		// expanded tabs
+		{
+			"a\tb",
+			10,
+			func(f *Wrap) {},
+			2,
+			0,
+			4,
+		},
+		// kept tabs
+		{
+			"a\tb",
+			10,
+			func(f *Wrap) { f.KeepTabs = true },
+			2,
+			0,
+			4,
+		},
+		// markers
+		{
+			"abcdef",
+			4,
+			func(f *Wrap) { f.StartMarker = "> " },
+			5,
+			1,
+			3,
+		},
+		// content moving to the next line for EndMarker
+		{
+			"abcdefgh",
+			5,
+			func(f *Wrap) { f.StartMarker = "> "; f.EndMarker = " \\" },
+			4,
+			1,
+			3,
+		},
+	}
+
+	for i, tc := range tt {
+		f := NewWriter(tc.Limit)
+		f.RecordLines = true
+		tc.Setup(f)
+		_, _ = f.Write([]byte(tc.Input))
+		_ = f.Close()
+
+		src, lines := []byte(tc.Input), f.Lines()
+		if row, col := position.SourceToVisual(src, lines, tc.Offset); row != tc.Row || col != tc.Col {
+			t.Errorf("Test %d, expected:\n\n`%d:%d`\n\nActual Output:\n\n`%d:%d`", i, tc.Row, tc.Col, row, col)
+		}
+		if offset := position.VisualToSource(src, lines, tc.Row, tc.Col); offset != tc.Offset {
+			t.Errorf("Test %d, expected:\n\n`%d`\n\nActual Output:\n\n`%d`", i, tc.Offset, offset)
+		}
+	}
+}